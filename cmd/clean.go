@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/reckerp/gch/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanDryRun    bool
+	cleanYes       bool
+	cleanForce     bool
+	cleanStaleDays int
+
+	// cleanCmd deletes merged and stale local branches
+	cleanCmd = &cobra.Command{
+		Use:   "clean",
+		Short: "Delete merged, squash-merged, gone and stale local branches",
+		Long: `Find local branches that are safe to remove and delete them after confirmation.
+
+A branch is a candidate when it is merged into the default branch (including
+squash merges), when its upstream branch was deleted, or when it has no commits
+for --stale-days days. Stale branches that aren't merged are only deleted after
+picking them in the checklist, or with --yes --force. The current branch, branches checked out in a worktree
+and protected branches (gch.protected, default: main, master, develop, release/*)
+are never deleted.
+
+Examples:
+  gch clean                   # Pick branches to delete from a checklist
+  gch clean --dry-run         # Only list the branches that would be deleted
+  gch clean --stale-days 90   # Also include branches untouched for 90 days
+  gch clean --yes             # Delete all merged and gone candidates without asking`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !git.IsGitRepo() {
+				fmt.Fprintln(os.Stderr, "Error: not a git repository")
+				os.Exit(1)
+			}
+
+			err := git.Clean(git.CleanOptions{
+				DryRun:    cleanDryRun,
+				Yes:       cleanYes,
+				Force:     cleanForce,
+				StaleDays: cleanStaleDays,
+				Debug:     debugMode,
+			})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	cleanCmd.Flags().BoolVarP(&cleanDryRun, "dry-run", "n", false, "List the branches that would be deleted without deleting them")
+	cleanCmd.Flags().BoolVarP(&cleanYes, "yes", "y", false, "Delete without asking for confirmation")
+	cleanCmd.Flags().BoolVarP(&cleanForce, "force", "f", false, "With --yes, also delete stale branches that aren't merged")
+	cleanCmd.Flags().IntVar(&cleanStaleDays, "stale-days", 0, "Also delete branches without commits for this many days (0 disables)")
+	RootCmd.AddCommand(cleanCmd)
+}
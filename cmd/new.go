@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/reckerp/gch/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newType  string
+	newFrom  string
+	newStash bool
+
+	// newCmd creates a branch named from the branch name template
+	newCmd = &cobra.Command{
+		Use:   "new <ticket> [title...]",
+		Short: "Create a branch named from a ticket ID and title",
+		Long: `Create and checkout a branch whose name is rendered from the branch name
+template (gch.branchTemplate, default "{{type}}/{{ticket}}-{{slug}}").
+
+The title is turned into a lower case slug; accents are stripped, other scripts
+are kept and the slug is truncated at a word boundary (gch.slugMaxLength).
+The ticket can be omitted, in which case all arguments form the title. With an
+issue tracker configured (gch.issues.provider), the title can be omitted instead
+and the issue's summary is used.
+
+Examples:
+  gch new PROJ-123 "Add login page"       # feat/PROJ-123-add-login-page
+  gch new --type fix PROJ-7 Crash on save  # fix/PROJ-7-crash-on-save
+  gch new "Update dependencies"           # feat/update-dependencies
+  gch new PROJ-42                          # feat/PROJ-42-<slug of the issue summary>`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if !git.IsGitRepo() {
+				fmt.Fprintln(os.Stderr, "Error: not a git repository")
+				os.Exit(1)
+			}
+
+			opts := git.Options{
+				Stash:   newStash,
+				Debug:   debugMode,
+				Offline: offline,
+				From:    newFrom,
+				Type:    newType,
+			}
+			err := git.NewBranch(args[0], strings.Join(args[1:], " "), opts)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	newCmd.Flags().StringVar(&newType, "type", "", "Branch type for the template (default gch.defaultType or feat)")
+	newCmd.Flags().StringVar(&newFrom, "from", "", "Base for the new branch, matched like a checkout pattern")
+	newCmd.Flags().BoolVarP(&newStash, "stash", "s", false, "Stash changes before creating the branch")
+	RootCmd.AddCommand(newCmd)
+}
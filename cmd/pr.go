@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/reckerp/gch/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	prRemote string
+	prForce  bool
+	prStash  bool
+	prDirty  string
+
+	// prCmd checks out a pull or merge request
+	prCmd = &cobra.Command{
+		Use:   "pr <number>",
+		Short: "Checkout a pull or merge request as a local pr/<number> branch",
+		Long: `Fetch the head of a pull request (GitHub) or merge request (GitLab) and check it
+out as a local pr/<number> branch that tracks it. Running it again fast-forwards
+the branch to the latest head.
+
+The ref is looked up as refs/pull/<number>/head and refs/merge-requests/<number>/head.
+Remotes with another layout can set a template, e.g.:
+  git config gch.remote.origin.prRef 'refs/changes/{{number}}/head'
+
+Examples:
+  gch pr 123              # Checkout pull request #123 from origin as pr/123
+  gch pr 42 -r upstream   # Checkout #42 from the upstream remote`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if !git.IsGitRepo() {
+				fmt.Fprintln(os.Stderr, "Error: not a git repository")
+				os.Exit(1)
+			}
+
+			number, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: '%s' is not a pull request number\n", args[0])
+				os.Exit(1)
+			}
+
+			opts := git.Options{
+				Force:   prForce,
+				Stash:   prStash,
+				OnDirty: prDirty,
+				Debug:   debugMode,
+				Offline: offline,
+			}
+			if err := git.CheckoutPR(number, prRemote, opts); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	prCmd.Flags().StringVarP(&prRemote, "remote", "r", "", "Remote to fetch the pull request from (default gch.prRemote, checkout.defaultRemote or origin)")
+	prCmd.Flags().BoolVarP(&prForce, "force", "f", false, "Force checkout, discarding any local changes")
+	prCmd.Flags().BoolVarP(&prStash, "stash", "s", false, "Stash changes before checkout")
+	prCmd.Flags().StringVar(&prDirty, "on-dirty", "", "What to do when local changes are in the way, without asking: stash, stash-all, merge, wip, discard or abort")
+	RootCmd.AddCommand(prCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/reckerp/gch/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recoverList bool
+
+	// recoverCmd restores the snapshots taken before force checkouts
+	recoverCmd = &cobra.Command{
+		Use:   "recover [snapshot]",
+		Short: "List and restore the snapshots of changes discarded by a force checkout",
+		Long: `Before a force checkout (-f) or discarding local changes, gch saves them as a
+snapshot under refs/gch/snapshots. gch recover restores one on top of the work tree.
+
+Without an argument it lets you pick a snapshot, or lists them when there is no terminal.
+Old snapshots are pruned according to gch.snapshots.keep and gch.snapshots.maxAge.
+
+Examples:
+  gch recover                      # Pick a snapshot to restore
+  gch recover --list               # List the snapshots
+  gch recover 20261018-152412.000  # Restore a snapshot (a unique prefix is enough)`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if !git.IsGitRepo() {
+				fmt.Fprintln(os.Stderr, "Error: not a git repository")
+				os.Exit(1)
+			}
+
+			var err error
+			if recoverList {
+				err = git.ListSnapshots()
+			} else {
+				id := ""
+				if len(args) > 0 {
+					id = args[0]
+				}
+				err = git.Recover(id)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	recoverCmd.Flags().BoolVarP(&recoverList, "list", "l", false, "List the snapshots instead of restoring one")
+	RootCmd.AddCommand(recoverCmd)
+}
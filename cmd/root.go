@@ -13,6 +13,14 @@ var (
 	createBranch bool
 	force        bool
 	stash        bool
+	columns      string
+	offline      bool
+	from         string
+	branchType   string
+	ticket       string
+	tags         bool
+	refs         bool
+	onDirty      string
 
 	// RootCmd represents the base command when called without any subcommands
 	RootCmd = &cobra.Command{
@@ -29,26 +37,55 @@ Features:
   • Smart branch creation
   • Force checkout support
   • Automatic stashing
+  • Branch metadata columns (ahead/behind, last commit age, author)
+  • Tags and recent commits with --tags and --refs
+  • Pull/merge request checkout
+  • Pull request badges from GitHub or GitLab (gch.forge.provider)
+  • Issue titles and status from Jira or Linear (gch.issues.provider)
+  • Undo of the last operation with gch undo
+  • Snapshots of changes discarded by -f, restored with gch recover
 
 Examples:
   # Checkout a branch using partial name
   gch prod            # Checkout branch containing 'prod'
   gch 123             # Checkout branch containing '123'
+  gch '#482'          # Checkout the branch of pull request #482
   
   # Create and checkout a new branch
   gch -b feature      # Create and checkout new branch 'feature'
   gch -b feat/user    # Create and checkout new branch 'feat/user'
+  gch -b fix --from ^ # Create 'fix' from the freshly fetched default branch
+  gch -b "Add login" --ticket PROJ-1  # Create 'feat/PROJ-1-add-login' from the template
   
   # Force checkout (discard local changes)
   gch -f prod         # Force checkout branch containing 'prod'
   gch -b -f feature   # Force create and checkout new branch
+  gch recover         # Restore changes a force checkout discarded
   
   # Always stash changes before checkout
   gch -s prod         # Stash changes and checkout branch containing 'prod'
   gch -s -b feature   # Stash changes and create/checkout new branch
+  gch --on-dirty=merge prod  # Carry changes over if they are in the way
   
+  # Navigate without typing branch names
+  gch -               # Switch to the previous branch
+  gch ^               # Switch to the remote's default branch (also: gch @default)
+  gch @up             # Switch to the upstream of the current branch
+  gch -- clean        # Match branches named like a subcommand, e.g. clean, new, pr or undo
+
+  # Check out tags and commits (detached, or create a branch there)
+  gch --tags v1.2     # Checkout the tag matching 'v1.2'
+  gch --refs "fix login"  # Also match recent commits by subject
+
+  # Checkout a pull or merge request as pr/123
+  gch pr 123
+
+  # Undo the last checkout, branch creation or stash
+  gch undo
+
   # Show interactive branch selector
-  gch                 # List all branches for interactive selection`,
+  gch                 # List all branches for interactive selection
+  gch --columns=track,subject  # Show upstream status and last commit subject`,
 		Args: cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			// Check if we're in a git repository
@@ -62,9 +99,24 @@ Examples:
 				pattern = args[0]
 			}
 
+			opts := git.Options{
+				CreateBranch: createBranch,
+				Force:        force,
+				Stash:        stash,
+				Debug:        debugMode,
+				Columns:      columns,
+				Offline:      offline,
+				From:         from,
+				Type:         branchType,
+				Ticket:       ticket,
+				Tags:         tags,
+				Refs:         refs,
+				OnDirty:      onDirty,
+			}
+
 			// If no pattern provided, show interactive branch selector
 			if pattern == "" {
-				if err := git.ShowInteractiveBranchSelector(debugMode); err != nil {
+				if err := git.ShowInteractiveBranchSelector(opts); err != nil {
 					fmt.Fprintln(os.Stderr, err)
 					os.Exit(1)
 				}
@@ -72,7 +124,7 @@ Examples:
 			}
 
 			// Otherwise use smart checkout with pattern
-			err := git.SmartCheckout(pattern, createBranch, force, stash, debugMode)
+			err := git.SmartCheckout(pattern, opts)
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
@@ -86,4 +138,12 @@ func init() {
 	RootCmd.Flags().BoolVarP(&createBranch, "branch", "b", false, "Create and checkout a new branch with the given name")
 	RootCmd.Flags().BoolVarP(&force, "force", "f", false, "Force checkout, discarding any local changes")
 	RootCmd.Flags().BoolVarP(&stash, "stash", "s", false, "Always stash changes before checkout")
+	RootCmd.Flags().StringVar(&onDirty, "on-dirty", "", "What to do when local changes are in the way, without asking: stash, stash-all, merge, wip, discard or abort")
+	RootCmd.Flags().StringVar(&ticket, "ticket", "", "Ticket ID for the branch name template; with -b the pattern becomes the title")
+	RootCmd.Flags().StringVar(&branchType, "type", "", "Branch type for the branch name template (default gch.defaultType or feat)")
+	RootCmd.Flags().StringVar(&from, "from", "", "Base for a new branch created with -b, matched like a checkout pattern (default gch.createFrom or HEAD)")
+	RootCmd.Flags().BoolVar(&tags, "tags", false, "Also match tags, sorted by gch.tagSort (version or date)")
+	RootCmd.Flags().BoolVar(&refs, "refs", false, "Also match tags and recent commits from all refs, identified by subject")
+	RootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Never access the network; use cached remote branches only")
+	RootCmd.PersistentFlags().StringVar(&columns, "columns", git.DefaultColumns, "Branch metadata columns shown in the selector (track, age, author, subject or none)")
 }
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/reckerp/gch/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	undoForce bool
+
+	// undoCmd reverses the last gch operation
+	undoCmd = &cobra.Command{
+		Use:   "undo",
+		Short: "Undo the last checkout, branch creation or stash made by gch",
+		Long: `Reverse the last operation recorded in gch's journal (.git/gch/journal):
+switch back to where HEAD was, delete the branch gch created if nothing was
+committed to it since, and re-apply the stash gch made.
+
+Running it again undoes the operation before that.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !git.IsGitRepo() {
+				fmt.Fprintln(os.Stderr, "Error: not a git repository")
+				os.Exit(1)
+			}
+
+			if err := git.Undo(undoForce); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	undoCmd.Flags().BoolVarP(&undoForce, "force", "f", false, "Undo even if HEAD moved since the operation")
+	RootCmd.AddCommand(undoCmd)
+}
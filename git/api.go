@@ -0,0 +1,83 @@
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// apiClient is the HTTP client used for forge and issue tracker APIs
+var apiClient = &http.Client{Timeout: 15 * time.Second}
+
+// apiError is returned when an API responds with an unexpected status
+type apiError struct {
+	url    string
+	status int
+	text   string
+}
+
+// Error returns the error message
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s returned %s", e.url, e.text)
+}
+
+// isRateLimited reports whether err is an API refusing requests because of
+// its rate limit. GitHub answers 403 once the limit is used up.
+func isRateLimited(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) &&
+		(apiErr.status == http.StatusForbidden || apiErr.status == http.StatusTooManyRequests)
+}
+
+// callAPI sends a request with the given headers and, if body isn't nil, a
+// JSON body. The JSON response is decoded into out. Empty header values are
+// left out, so anonymous requests can pass an empty token.
+func callAPI(method, rawURL string, headers map[string]string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		if value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		u, _ := url.Parse(rawURL)
+		return &apiError{url: u.Redacted(), status: resp.StatusCode, text: resp.Status}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// bearer returns an Authorization header value for token, or an empty
+// string for anonymous requests
+func bearer(token string) string {
+	if token == "" {
+		return ""
+	}
+	return "Bearer " + token
+}
@@ -0,0 +1,450 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefKind is the kind of ref a Branch stands for. Tags and commits are
+// only listed with --tags or --refs.
+type RefKind int
+
+const (
+	KindBranch RefKind = iota
+	KindTag
+	KindCommit
+)
+
+// String returns the name of the kind
+func (k RefKind) String() string {
+	switch k {
+	case KindTag:
+		return "tag"
+	case KindCommit:
+		return "commit"
+	}
+	return "branch"
+}
+
+// Branch represents a git branch, or a tag or commit to check out
+type Branch struct {
+	Name    string
+	IsLocal bool
+	Current bool
+	Kind    RefKind
+	// Hash is the full object name of a commit
+	Hash string
+
+	// Remote is the remote a remote-only branch lives on (e.g. "origin")
+	Remote string
+	// Upstream is the short name of the configured upstream (e.g. "origin/main")
+	Upstream string
+	// Ahead and Behind count commits relative to the upstream
+	Ahead  int
+	Behind int
+	// Gone is true when the upstream is configured but no longer exists
+	Gone bool
+	// Advertised is true for remote branches only known from ls-remote,
+	// which have to be fetched before they can be checked out
+	Advertised bool
+
+	CommitDate time.Time
+	Author     string
+	Subject    string
+
+	// PR is the branch's open pull request when a forge is configured
+	PR *PullRequest
+	// Issue is the ticket named in the branch when an issue tracker is
+	// configured
+	Issue *Issue
+}
+
+// String returns the string representation of a branch
+func (b Branch) String() string {
+	if b.Current {
+		return "* " + b.Name
+	}
+
+	switch b.Kind {
+	case KindTag:
+		return "  " + b.Name + " (tag)"
+	case KindCommit:
+		return "  " + b.Name + " " + b.Subject + " (commit)"
+	}
+
+	if b.Advertised {
+		return "  " + b.Name + " (remote, not fetched)"
+	}
+
+	if !b.IsLocal {
+		return "  " + b.Name + " (remote)"
+	}
+
+	return "  " + b.Name
+}
+
+// RemoteRef returns the remote-tracking ref a remote-only branch is created from
+func (b Branch) RemoteRef() string {
+	remote := b.Remote
+	if remote == "" {
+		remote = "origin"
+	}
+	return remote + "/" + b.Name
+}
+
+// Ref returns the revision the entry points at, e.g. "refs/tags/v1.0" for
+// a tag
+func (b Branch) Ref() string {
+	switch b.Kind {
+	case KindTag:
+		return "refs/tags/" + b.Name
+	case KindCommit:
+		return b.Hash
+	}
+	if b.IsLocal {
+		return b.Name
+	}
+	return b.RemoteRef()
+}
+
+// matchText returns the text patterns are matched against. Commits are
+// identified by their subject as well as their hash.
+func (b Branch) matchText() string {
+	if b.Kind == KindCommit {
+		return b.Name + " " + b.Subject
+	}
+	return b.Name
+}
+
+// searchText returns the text the selector searches, which also includes
+// the number and title of the branch's pull request and its issue's title
+func (b Branch) searchText() string {
+	text := b.matchText()
+	if b.PR != nil {
+		text += " #" + strconv.Itoa(b.PR.Number) + " " + b.PR.Title
+	}
+	if b.Issue != nil {
+		text += " " + b.Issue.Title
+	}
+	return text
+}
+
+// label returns the branch as shown in the selector, with the badges of its
+// pull request and issue
+func (b Branch) label() string {
+	label := b.String()
+	if b.PR != nil {
+		label += "  " + b.PR.badge()
+	}
+	if b.Issue != nil {
+		label += "  " + b.Issue.badge()
+	}
+	return label
+}
+
+// checkoutArgs returns the git arguments used to check out the branch,
+// creating a local branch for remote-only branches the way git would. Tags
+// and commits are checked out as a detached HEAD.
+func checkoutArgs(b Branch) []string {
+	if b.Kind != KindBranch {
+		return detachArgs(b.Ref())
+	}
+	if b.IsLocal {
+		return switchArgs(b.Name)
+	}
+	return remoteBranchArgs(b)
+}
+
+// column identifies an optional metadata column shown next to a branch name
+type column int
+
+const (
+	colTrack column = iota
+	colAge
+	colAuthor
+	colSubject
+)
+
+// columnNames maps the names accepted by --columns to columns
+var columnNames = map[string]column{
+	"track":   colTrack,
+	"age":     colAge,
+	"author":  colAuthor,
+	"subject": colSubject,
+}
+
+// DefaultColumns is the column list used when none is configured
+const DefaultColumns = "track,age,author"
+
+// parseColumns parses a comma separated list of column names
+func parseColumns(spec string) ([]column, error) {
+	var cols []column
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" || name == "none" {
+			continue
+		}
+		col, ok := columnNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q (available: track, age, author, subject)", name)
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// cell returns the text of a single metadata column for the branch
+func (b Branch) cell(col column) string {
+	switch col {
+	case colTrack:
+		return b.trackStatus()
+	case colAge:
+		if b.CommitDate.IsZero() {
+			return ""
+		}
+		return formatAge(time.Since(b.CommitDate))
+	case colAuthor:
+		return b.Author
+	case colSubject:
+		return b.Subject
+	}
+	return ""
+}
+
+// trackStatus describes how the branch relates to its upstream
+func (b Branch) trackStatus() string {
+	if b.Gone {
+		return "gone"
+	}
+	if b.Upstream == "" {
+		return ""
+	}
+
+	var parts []string
+	if b.Ahead > 0 {
+		parts = append(parts, "↑"+strconv.Itoa(b.Ahead))
+	}
+	if b.Behind > 0 {
+		parts = append(parts, "↓"+strconv.Itoa(b.Behind))
+	}
+	if len(parts) == 0 {
+		return "="
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatAge formats a duration as a short relative age like "3d" or "5mo"
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return strconv.Itoa(int(d.Minutes())) + "m"
+	case d < 24*time.Hour:
+		return strconv.Itoa(int(d.Hours())) + "h"
+	case d < 14*24*time.Hour:
+		return strconv.Itoa(int(d.Hours()/24)) + "d"
+	case d < 60*24*time.Hour:
+		return strconv.Itoa(int(d.Hours()/(24*7))) + "w"
+	case d < 365*24*time.Hour:
+		return strconv.Itoa(int(d.Hours()/(24*30))) + "mo"
+	}
+	return strconv.Itoa(int(d.Hours()/(24*365))) + "y"
+}
+
+// formatBranchRows renders branches as rows with the given metadata columns
+// aligned. Rows are returned in the same order as the branches.
+func formatBranchRows(branches []Branch, cols []column) []string {
+	rows := make([]string, len(branches))
+	if len(cols) == 0 {
+		for i, b := range branches {
+			rows[i] = b.label()
+		}
+		return rows
+	}
+
+	// Measure every column so rows line up
+	labelWidth := 0
+	widths := make([]int, len(cols))
+	for _, b := range branches {
+		labelWidth = max(labelWidth, displayWidth(b.label()))
+		for j, col := range cols {
+			widths[j] = max(widths[j], displayWidth(b.cell(col)))
+		}
+	}
+
+	for i, b := range branches {
+		var sb strings.Builder
+		sb.WriteString(padRight(b.label(), labelWidth))
+		for j, col := range cols {
+			sb.WriteString("  ")
+			sb.WriteString(padRight(b.cell(col), widths[j]))
+		}
+		rows[i] = strings.TrimRight(sb.String(), " ")
+	}
+	return rows
+}
+
+// displayWidth returns the number of runes in s
+func displayWidth(s string) int {
+	return len([]rune(s))
+}
+
+// padRight pads s with spaces up to width runes
+func padRight(s string, width int) string {
+	if n := displayWidth(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// branchRefFormat is the for-each-ref format used by getAllBranches. Fields
+// are separated by NUL bytes so subjects and author names can contain anything.
+const branchRefFormat = "%(refname)%00%(HEAD)%00%(upstream:short)%00%(upstream:track)%00" +
+	"%(committerdate:unix)%00%(authorname)%00%(subject)%00%(objectname)"
+
+// getAllBranches returns all branches, both local and remote, together with
+// their upstream and last commit metadata
+func getAllBranches() ([]Branch, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format="+branchRefFormat, "refs/heads", "refs/remotes")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branches: %w", err)
+	}
+
+	remoteNames, _ := listRemotes()
+	var locals, remotes []Branch
+	for _, line := range strings.Split(string(output), "\n") {
+		b, ok := parseBranchRef(line, remoteNames)
+		if !ok {
+			continue
+		}
+		if b.IsLocal {
+			locals = append(locals, b)
+		} else {
+			remotes = append(remotes, b)
+		}
+	}
+
+	// Add local branches first, then remote branches that don't have a
+	// local counterpart. Prefer checkout.defaultRemote, or else origin, when
+	// several remotes have the branch.
+	preferred := defaultRemote()
+	seen := make(map[string]bool)
+	result := make([]Branch, 0, len(locals)+len(remotes))
+	for _, b := range locals {
+		seen[b.Name] = true
+		result = append(result, b)
+	}
+	for _, pass := range []bool{true, false} {
+		for _, b := range remotes {
+			if seen[b.Name] || (b.Remote == preferred) != pass {
+				continue
+			}
+			seen[b.Name] = true
+			result = append(result, b)
+		}
+	}
+
+	return result, nil
+}
+
+// parseBranchRef parses one line of for-each-ref output in branchRefFormat.
+// remotes are the configured remotes, to tell them from the branch name.
+func parseBranchRef(line string, remotes []string) (Branch, bool) {
+	fields := strings.Split(line, "\x00")
+	if len(fields) != 8 {
+		return Branch{}, false
+	}
+
+	var b Branch
+	refname := fields[0]
+	switch {
+	case strings.HasPrefix(refname, "refs/heads/"):
+		b.Name = strings.TrimPrefix(refname, "refs/heads/")
+		b.IsLocal = true
+		b.Current = fields[1] == "*"
+	case strings.HasPrefix(refname, "refs/remotes/"):
+		remote, name, ok := splitRemoteRef(strings.TrimPrefix(refname, "refs/remotes/"), remotes)
+		// Skip HEAD reference
+		if !ok || name == "HEAD" {
+			return Branch{}, false
+		}
+		b.Name = name
+		b.Remote = remote
+	default:
+		return Branch{}, false
+	}
+
+	b.Upstream = fields[2]
+	b.Ahead, b.Behind, b.Gone = parseTrack(fields[3])
+	if ts, err := strconv.ParseInt(fields[4], 10, 64); err == nil {
+		b.CommitDate = time.Unix(ts, 0)
+	}
+	b.Author = fields[5]
+	b.Subject = fields[6]
+	b.Hash = fields[7]
+
+	return b, true
+}
+
+// parseTrack parses %(upstream:track) output such as "[ahead 1, behind 2]"
+// or "[gone]"
+func parseTrack(track string) (ahead, behind int, gone bool) {
+	track = strings.Trim(track, "[]")
+	if track == "gone" {
+		return 0, 0, true
+	}
+	for _, part := range strings.Split(track, ",") {
+		kind, count, ok := strings.Cut(strings.TrimSpace(part), " ")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.Atoi(count)
+		switch kind {
+		case "ahead":
+			ahead = n
+		case "behind":
+			behind = n
+		}
+	}
+	return ahead, behind, false
+}
+
+// getCurrentBranch returns the current branch name, or an empty string when
+// HEAD is detached
+func getCurrentBranch() (string, error) {
+	cmd := exec.Command("git", "symbolic-ref", "--quiet", "--short", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		// symbolic-ref --quiet exits with 1 when HEAD is detached
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// getDefaultBranch returns the name of the remote's default branch (e.g.
+// "main") and the ref that best represents it: the remote-tracking branch
+// when refs/remotes/<remote>/HEAD is known, otherwise a local main or master
+// branch
+func getDefaultBranch() (name, ref string, err error) {
+	if remote := defaultRemote(); remote != "" {
+		output, err := gitOutput("symbolic-ref", "--quiet", "--short", "refs/remotes/"+remote+"/HEAD")
+		if err == nil && output != "" {
+			return strings.TrimPrefix(output, remote+"/"), output, nil
+		}
+	}
+
+	for _, candidate := range []string{"main", "master"} {
+		if localBranchExists(candidate) {
+			return candidate, candidate, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("could not determine the default branch")
+}
@@ -0,0 +1,76 @@
+package git
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBranchRef(t *testing.T) {
+	remotes := []string{"origin", "team", "team/origin"}
+	ref := func(refname, head, upstream, track string) string {
+		return refname + "\x00" + head + "\x00" + upstream + "\x00" + track + "\x00" +
+			"1700000000\x00Jane Doe\x00Add login\x00abc123"
+	}
+	tests := []struct {
+		name   string
+		line   string
+		want   Branch
+		wantOK bool
+	}{
+		{"current local branch", ref("refs/heads/main", "*", "origin/main", "[ahead 1]"),
+			Branch{Name: "main", IsLocal: true, Current: true, Upstream: "origin/main", Ahead: 1}, true},
+		{"local branch with slashes", ref("refs/heads/feat/login", " ", "", ""),
+			Branch{Name: "feat/login", IsLocal: true}, true},
+		{"remote branch", ref("refs/remotes/origin/feat/login", " ", "", ""),
+			Branch{Name: "feat/login", Remote: "origin"}, true},
+		{"remote with a slash", ref("refs/remotes/team/origin/feat/login", " ", "", ""),
+			Branch{Name: "feat/login", Remote: "team/origin"}, true},
+		{"shorter remote", ref("refs/remotes/team/feat/login", " ", "", ""),
+			Branch{Name: "feat/login", Remote: "team"}, true},
+		{"unknown remote", ref("refs/remotes/gone/feat/login", " ", "", ""),
+			Branch{Name: "feat/login", Remote: "gone"}, true},
+		{"remote HEAD", ref("refs/remotes/team/origin/HEAD", " ", "", ""), Branch{}, false},
+		{"tag", ref("refs/tags/v1.0", " ", "", ""), Branch{}, false},
+		{"missing fields", "refs/heads/main\x00*", Branch{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseBranchRef(tt.line, remotes)
+			if ok != tt.wantOK {
+				t.Fatalf("parseBranchRef() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			tt.want.CommitDate = time.Unix(1700000000, 0)
+			tt.want.Author, tt.want.Subject, tt.want.Hash = "Jane Doe", "Add login", "abc123"
+			if got != tt.want {
+				t.Errorf("parseBranchRef() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrack(t *testing.T) {
+	tests := []struct {
+		track      string
+		wantAhead  int
+		wantBehind int
+		wantGone   bool
+	}{
+		{"", 0, 0, false},
+		{"[ahead 3]", 3, 0, false},
+		{"[behind 2]", 0, 2, false},
+		{"[ahead 1, behind 12]", 1, 12, false},
+		{"[gone]", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.track, func(t *testing.T) {
+			ahead, behind, gone := parseTrack(tt.track)
+			if ahead != tt.wantAhead || behind != tt.wantBehind || gone != tt.wantGone {
+				t.Errorf("parseTrack(%q) = %d, %d, %v, want %d, %d, %v",
+					tt.track, ahead, behind, gone, tt.wantAhead, tt.wantBehind, tt.wantGone)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bulkAction is an operation applied to every marked branch in the selector
+type bulkAction int
+
+const (
+	actionDeleteLocal bulkAction = iota
+	actionDeleteRemote
+	actionPruneMerged
+	actionCreateWorktrees
+)
+
+// bulkActionNames are the labels shown in the action menu, in menu order
+var bulkActionNames = []string{
+	"Delete local branches",
+	"Delete remote branches",
+	"Prune merged branches",
+	"Create worktrees",
+}
+
+// bulkStep is a single git command planned for one branch. Steps with a
+// skip reason are listed in the confirmation but never run.
+type bulkStep struct {
+	branch string
+	args   []string
+	skip   string
+}
+
+// bulkResult is the outcome of running a bulk step
+type bulkResult struct {
+	step   bulkStep
+	output string
+	err    error
+}
+
+// bulkDoneMsg is sent when all steps of a bulk action have run
+type bulkDoneMsg []bulkResult
+
+// bulkState tracks which screen of the bulk flow is shown
+type bulkState int
+
+const (
+	bulkMenu bulkState = iota
+	bulkConfirm
+	bulkRunning
+	bulkDone
+)
+
+// bulkModel drives the action menu, confirmation and results for a set of
+// marked branches
+type bulkModel struct {
+	branches []Branch
+	state    bulkState
+	cursor   int
+	steps    []bulkStep
+	results  []bulkResult
+	finished bool
+}
+
+// newBulkModel creates a bulk model for the given marked branches
+func newBulkModel(branches []Branch) *bulkModel {
+	return &bulkModel{branches: branches}
+}
+
+// Update handles messages for the bulk flow. finished is set once the user
+// leaves the flow, either by cancelling or after viewing the results.
+func (m *bulkModel) Update(msg tea.Msg) tea.Cmd {
+	if results, ok := msg.(bulkDoneMsg); ok {
+		m.results = results
+		m.state = bulkDone
+		return nil
+	}
+
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	switch m.state {
+	case bulkMenu:
+		switch key.String() {
+		case "up", "k", "ctrl+p":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j", "ctrl+n":
+			if m.cursor < len(bulkActionNames)-1 {
+				m.cursor++
+			}
+		case "enter":
+			m.steps = planBulkAction(bulkAction(m.cursor), m.branches)
+			m.state = bulkConfirm
+		case "esc", "q", "ctrl+c":
+			m.finished = true
+		}
+
+	case bulkConfirm:
+		switch key.String() {
+		case "y", "Y":
+			m.state = bulkRunning
+			steps := m.steps
+			return func() tea.Msg {
+				return runBulkSteps(steps)
+			}
+		case "n", "N", "esc", "q", "ctrl+c":
+			m.state = bulkMenu
+		}
+
+	case bulkDone:
+		m.finished = true
+	}
+
+	return nil
+}
+
+// View renders the current screen of the bulk flow
+func (m *bulkModel) View() string {
+	var sb strings.Builder
+
+	switch m.state {
+	case bulkMenu:
+		sb.WriteString(fmt.Sprintf("%d branches marked. What would you like to do?\n\n", len(m.branches)))
+		for i, name := range bulkActionNames {
+			cursor := " "
+			if m.cursor == i {
+				cursor = ">"
+			}
+			sb.WriteString(fmt.Sprintf("%s %s\n", cursor, name))
+		}
+		sb.WriteString("\nPress Enter to choose, Esc to go back")
+
+	case bulkConfirm:
+		sb.WriteString(bulkActionNames[m.cursor] + ". The following will happen:\n\n")
+		runnable := 0
+		for _, step := range m.steps {
+			if step.skip != "" {
+				sb.WriteString(fmt.Sprintf("  skip %s: %s\n", step.branch, step.skip))
+				continue
+			}
+			runnable++
+			sb.WriteString("  git " + strings.Join(step.args, " ") + "\n")
+		}
+		if runnable == 0 {
+			sb.WriteString("\nNothing to do. Press Esc to go back")
+		} else {
+			sb.WriteString(fmt.Sprintf("\nRun %d commands? [y/N]", runnable))
+		}
+
+	case bulkRunning:
+		sb.WriteString(bulkActionNames[m.cursor] + "...\n")
+
+	case bulkDone:
+		failed := 0
+		for _, r := range m.results {
+			if r.err != nil {
+				failed++
+				sb.WriteString(fmt.Sprintf("  ✗ %s: %s\n", r.step.branch, firstLine(r.output)))
+			} else {
+				sb.WriteString(fmt.Sprintf("  ✓ %s\n", r.step.branch))
+			}
+		}
+		sb.WriteString(fmt.Sprintf("\n%d succeeded, %d failed. Press any key to continue", len(m.results)-failed, failed))
+	}
+
+	return sb.String()
+}
+
+// planBulkAction builds the steps needed to apply action to branches
+func planBulkAction(action bulkAction, branches []Branch) []bulkStep {
+	var defaultName, defaultRef string
+	var protected []string
+	switch action {
+	case actionPruneMerged:
+		defaultName, defaultRef, _ = getDefaultBranch()
+	case actionDeleteRemote:
+		defaultName, _, _ = getDefaultBranch()
+		protected = protectedBranches(defaultName)
+	}
+
+	remotes, _ := listRemotes()
+	var steps []bulkStep
+	for _, b := range branches {
+		step := bulkStep{branch: b.Name}
+		if b.Kind != KindBranch {
+			step.skip = "not a branch"
+			steps = append(steps, step)
+			continue
+		}
+
+		switch action {
+		case actionDeleteLocal:
+			switch {
+			case !b.IsLocal:
+				step.skip = "no local branch"
+			case b.Current:
+				step.skip = "currently checked out"
+			default:
+				step.args = []string{"branch", "-d", b.Name}
+			}
+
+		case actionDeleteRemote:
+			// An upstream with another name, like the base a branch was
+			// created from, isn't this branch's to delete
+			remote, name := b.remoteBranch(remotes)
+			switch {
+			case remote == "":
+				step.skip = "no remote branch"
+			case name != b.Name:
+				step.skip = "upstream " + remote + "/" + name + " has another name"
+			case name == defaultName:
+				step.branch = remote + "/" + name
+				step.skip = "default branch"
+			case isProtected(name, protected):
+				step.branch = remote + "/" + name
+				step.skip = "protected"
+			default:
+				step.branch = remote + "/" + name
+				step.args = []string{"push", remote, "--delete", name}
+			}
+
+		case actionPruneMerged:
+			switch {
+			case !b.IsLocal:
+				step.skip = "no local branch"
+			case b.Current:
+				step.skip = "currently checked out"
+			case defaultRef == "":
+				step.skip = "default branch unknown"
+			case b.Name == defaultName:
+				step.skip = "default branch"
+			case !isMerged(b.Name, defaultRef):
+				step.skip = "not merged into " + defaultRef
+			default:
+				step.args = []string{"branch", "-d", b.Name}
+			}
+
+		case actionCreateWorktrees:
+			path := worktreePath(b.Name)
+			switch {
+			case b.Current:
+				step.skip = "currently checked out"
+			case b.IsLocal:
+				step.args = []string{"worktree", "add", path, b.Name}
+			default:
+				step.args = []string{"worktree", "add", "--track", "-b", b.Name, path, b.RemoteRef()}
+			}
+		}
+
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// runBulkSteps runs every non-skipped step and collects the results. A
+// failing step doesn't stop the remaining ones.
+func runBulkSteps(steps []bulkStep) bulkDoneMsg {
+	var results bulkDoneMsg
+	for _, step := range steps {
+		if step.skip != "" {
+			continue
+		}
+		output, err := execGitCommandWithOutput(step.args...)
+		results = append(results, bulkResult{step: step, output: strings.TrimSpace(output), err: err})
+	}
+	return results
+}
+
+// remoteBranch returns the remote and branch name the branch exists as on a
+// remote, either because it is remote-only or through its upstream. remotes
+// are the configured remotes.
+func (b Branch) remoteBranch(remotes []string) (remote, name string) {
+	if !b.IsLocal {
+		return b.Remote, b.Name
+	}
+	if b.Upstream == "" || b.Gone {
+		return "", ""
+	}
+	remote, name, ok := splitRemoteRef(b.Upstream, remotes)
+	if !ok {
+		return "", ""
+	}
+	return remote, name
+}
+
+// key uniquely identifies a branch in the selector
+func (b Branch) key() string {
+	if b.Kind != KindBranch {
+		return b.Ref()
+	}
+	if b.IsLocal {
+		return b.Name
+	}
+	return b.RemoteRef()
+}
+
+// isMerged reports whether branch is fully merged into target
+func isMerged(branch, target string) bool {
+	return exec.Command("git", "merge-base", "--is-ancestor", branch, target).Run() == nil
+}
+
+// worktreePath returns the directory a worktree for branch is created in: a
+// sibling of the repository named "<repo>-<branch>"
+func worktreePath(branch string) string {
+	top, err := execGitCommandWithOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		top = "."
+	}
+	top = strings.TrimSpace(top)
+	name := filepath.Base(top) + "-" + strings.ReplaceAll(branch, "/", "-")
+	return filepath.Join(filepath.Dir(top), name)
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -17,25 +17,118 @@ func IsGitRepo() bool {
 	return err == nil
 }
 
-// promptForStash shows an interactive prompt asking the user if they want to stash changes
-func promptForStash() (bool, error) {
-	model := createStashPromptModel()
+// promptForStash shows an interactive prompt asking the user what to do with
+// the changes in the way of the checkout
+func promptForStash(conflict checkoutConflict, choices []dirtyChoice) (dirtyChoice, error) {
+	model := createStashPromptModel(conflict, choices)
 	p := tea.NewProgram(model)
 	result, err := p.Run()
 	if err != nil {
-		return false, err
+		return dirtyChoice{}, err
 	}
 
 	// The result is the model, and we can check which option was selected
 	if m, ok := result.(*stashPromptModel); ok {
-		return m.cursor == 0, nil // true if "Stash changes" was selected
+		return m.choices[m.cursor], nil
 	}
-	return false, errors.New("unexpected result type from stash prompt")
+	return dirtyChoice{}, errors.New("unexpected result type from stash prompt")
+}
+
+// stashOptions selects what stashChanges stashes
+type stashOptions struct {
+	// untracked includes untracked files, like git stash -u
+	untracked bool
+	// paths limits the stash to these paths, relative to the top of the
+	// repository
+	paths []string
 }
 
 // stashChanges stashes the current changes
-func stashChanges() error {
-	return execGitCommand("stash", "push", "-m", "Auto-stashed by gch")
+func stashChanges(opts stashOptions) error {
+	return execGitCommand(stashArgs(opts)...)
+}
+
+// stashArgs returns the git stash push arguments for opts
+func stashArgs(opts stashOptions) []string {
+	args := []string{"stash", "push", "-m", "Auto-stashed by gch"}
+	if opts.untracked {
+		args = append(args, "--include-untracked")
+	}
+	if len(opts.paths) > 0 {
+		args = append(args, "--")
+		args = append(args, topPaths(opts.paths)...)
+	}
+	return args
+}
+
+// topPaths turns paths relative to the top of the repository, as git lists
+// them, into pathspecs that match them from any subdirectory
+func topPaths(paths []string) []string {
+	pathspecs := make([]string, len(paths))
+	for i, path := range paths {
+		pathspecs[i] = ":(top)" + path
+	}
+	return pathspecs
+}
+
+// splitNul splits the output of a git command run with -z
+func splitNul(output string) []string {
+	var fields []string
+	for _, field := range strings.Split(output, "\x00") {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// checkoutConflict describes the files in the way of a checkout
+type checkoutConflict struct {
+	// untracked is set when untracked files would be overwritten, which
+	// only a stash including untracked files can move out of the way
+	untracked bool
+	// files are relative to the top of the repository
+	files []string
+}
+
+// parseCheckoutConflict recognizes git's errors for local changes or
+// untracked files that would be overwritten by a checkout
+func parseCheckoutConflict(output string) (checkoutConflict, bool) {
+	var conflict checkoutConflict
+	found, listing := false, false
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "error: Your local changes to the following files would be overwritten by checkout"):
+			found, listing = true, true
+		case strings.HasPrefix(line, "error: The following untracked working tree files would be overwritten by checkout"):
+			found, listing, conflict.untracked = true, true, true
+		case listing && strings.HasPrefix(line, "\t"):
+			conflict.files = append(conflict.files, strings.TrimPrefix(line, "\t"))
+		default:
+			listing = false
+		}
+	}
+	return conflict, found
+}
+
+// conflictStash returns the stash that moves only the conflicting untracked
+// files out of the way, along with all changes to tracked files
+func conflictStash(conflict checkoutConflict) stashOptions {
+	paths := append([]string(nil), conflict.files...)
+	if output, err := gitOutput("diff", "--name-only", "-z", "--no-renames", "HEAD"); err == nil {
+		paths = append(paths, splitNul(output)...)
+	}
+	return stashOptions{untracked: true, paths: paths}
+}
+
+// untrackedFiles returns the untracked files that aren't ignored, listing
+// them from dir or the working directory when dir is empty
+func untrackedFiles(dir string) []string {
+	output, err := gitOutputIn(dir, "ls-files", "-z", "--others", "--exclude-standard", "--full-name", ":/")
+	if err != nil {
+		return nil
+	}
+	return splitNul(output)
 }
 
 // execGitCommandWithOutput executes a git command and returns its output
@@ -45,31 +138,95 @@ func execGitCommandWithOutput(args ...string) (string, error) {
 	return string(output), err
 }
 
+// gitOutput runs a git command and returns its trimmed standard output
+func gitOutput(args ...string) (string, error) {
+	return gitOutputIn("", args...)
+}
+
+// gitOutputIn runs a git command in dir, or the working directory when dir
+// is empty, and returns its trimmed standard output
+func gitOutputIn(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+// Options controls how gch checks out branches and renders the selector
+type Options struct {
+	CreateBranch bool
+	Force        bool
+	Stash        bool
+	Debug        bool
+	// OnDirty is how to deal with local changes in the way of a checkout
+	// without asking: stash, stash-all, merge, wip, discard or abort
+	OnDirty string
+	// Offline never touches the network
+	Offline bool
+	// From is the pattern of the base a new branch is created from
+	From string
+	// Type and Ticket fill the branch name template for new branches
+	Type   string
+	Ticket string
+	// Columns is a comma separated list of metadata columns shown in the selector
+	Columns string
+	// Tags also lists tags; Refs lists tags and recent commits
+	Tags bool
+	Refs bool
+}
+
 // SmartCheckout implements smart branch checkout functionality
-func SmartCheckout(pattern string, createBranch bool, force bool, stash bool, debug bool) error {
-	if pattern == "" {
-		// If no pattern provided, switch to the previous branch
-		return execGitCommand("checkout", "-")
+func SmartCheckout(pattern string, opts Options) error {
+	createBranch, debug := opts.CreateBranch, opts.Debug
+
+	op := "checkout"
+	if createBranch {
+		op = "create"
+	}
+	defer trackOperation(op)()
+
+	// Reserved navigation aliases resolve to exactly one branch
+	if isAlias(pattern) {
+		target, err := resolveAlias(pattern)
+		if err != nil {
+			return err
+		}
+		return checkoutBranch(target, opts)
 	}
 
 	// If createBranch is true, create and checkout a new branch
 	if createBranch {
-		fmt.Printf("Creating and checking out new branch: %s\n", pattern)
-		args := []string{"checkout", "-b", pattern}
-		if force {
-			args = append(args, "-f")
+		// With template variables the pattern is the title of the branch
+		if opts.Ticket != "" || opts.Type != "" {
+			name, err := renderBranchName(BranchNameVars{Type: opts.Type, Ticket: opts.Ticket, Title: pattern})
+			if err != nil {
+				return err
+			}
+			pattern = name
 		}
-		return execGitCommand(args...)
+		return createNewBranch(pattern, opts)
 	}
 
-	// Get all branches (local and remote)
-	branches, err := getAllBranches()
+	// Get all branches (local and remote), and tags or commits if asked for
+	branches, err := getRefs(opts)
 	if err != nil {
 		return err
 	}
 
+	// Link open pull requests so "#123" or "123" finds the branch of pull
+	// request 123
+	pulls, stale := loadPullRequests(opts.Offline)
+	if stale && isPRReference(pattern) {
+		if fresh, err := refreshForgeCache(); err == nil {
+			pulls = fresh
+		} else if debug {
+			fmt.Printf("Failed to load pull requests: %v\n", err)
+		}
+	}
+	attachPullRequests(branches, pulls)
+
 	if debug {
-		fmt.Printf("Found %d branches\n", len(branches))
+		fmt.Printf("Found %d branches and %d pull requests\n", len(branches), len(pulls))
 	}
 
 	// If no branches exist and no pattern provided, suggest creating a new branch
@@ -80,197 +237,185 @@ func SmartCheckout(pattern string, createBranch bool, force bool, stash bool, de
 	// Convert to branchMatch objects and score them
 	var matches []branchMatch
 	for _, branch := range branches {
-		score := calcMatchScore(branch.Name, pattern)
+		score := scoreBranch(branch, pattern)
 		if score > 0 { // Only add if there's some match
 			matches = append(matches, branchMatch{
-				name:    branch.Name,
-				isLocal: branch.IsLocal,
-				score:   score,
+				branch: branch,
+				score:  score,
 			})
 		}
 	}
 
-	if len(matches) == 0 {
-		// If no matches found, try fetching and searching again
-		if err := execGitCommand("fetch", "--quiet"); err != nil {
-			return fmt.Errorf("failed to fetch remote branches: %w", err)
+	if len(matches) == 0 && !opts.Offline {
+		// If no matches found, ask the remotes which branches they have
+		// instead of fetching everything. Only the chosen branch is fetched.
+		if debug {
+			fmt.Println("No local matches, looking up remote branches")
 		}
-
-		branches, err = getAllBranches()
+		matches, err = lsRemoteMatches(pattern)
 		if err != nil {
 			return err
 		}
+	}
 
-		matches = nil
-		for _, branch := range branches {
-			score := calcMatchScore(branch.Name, pattern)
-			if score > 0 {
-				matches = append(matches, branchMatch{
-					name:    branch.Name,
-					isLocal: branch.IsLocal,
-					score:   score,
-				})
-			}
-		}
-
-		if len(matches) == 0 {
-			return errors.New("no branches match '" + pattern + "'")
-		}
+	if len(matches) == 0 {
+		return offerCreateBranch(pattern, opts)
 	}
 
+	// A branch on several remotes resolves to checkout.defaultRemote, as
+	// in git
+	matches = preferDefaultRemote(matches)
+
 	// Sort matches by score (higher is better)
 	sortMatches(matches)
 
 	if debug {
 		fmt.Printf("Found %d matches:\n", len(matches))
 		for i, match := range matches {
-			fmt.Printf("%d. %s (score: %d, local: %v)\n", i+1, match.name, match.score, match.isLocal)
+			fmt.Printf("%d. %s (score: %d, local: %v)\n", i+1, match.branch.Name, match.score, match.branch.IsLocal)
 		}
 	}
 
-	bestMatch := matches[0]
 	// If we have a single match or one match is significantly better than others
-	if len(matches) == 1 || (len(matches) > 1 && bestMatch.score > matches[1].score*2) {
-		// Single match or one match is significantly better than others
-		if bestMatch.isLocal {
-			// Local branch
-			fmt.Printf("Checking out local branch: %s\n", bestMatch.name)
-
-			// If stash flag is set, always stash changes
-			if stash {
-				if err := stashChanges(); err != nil {
-					return fmt.Errorf("failed to stash changes: %w", err)
-				}
-			} else if !force {
-				// Only check for conflicts if not forcing and not stashing
-				output, err := execGitCommandWithOutput("checkout", bestMatch.name)
-				if err != nil {
-					if strings.Contains(output, "error: Your local changes to the following files would be overwritten by checkout") ||
-						strings.Contains(output, "error: The following untracked working tree files would be overwritten by checkout") {
-						// Checkout would fail, ask about stashing
-						stash, err := promptForStash()
-						if err != nil {
-							return err
-						}
-						if stash {
-							if err := stashChanges(); err != nil {
-								return fmt.Errorf("failed to stash changes: %w", err)
-							}
-							// Try the checkout again after stashing
-							return execGitCommand("checkout", bestMatch.name)
-						} else {
-							return errors.New("checkout aborted")
-						}
-					}
-					return fmt.Errorf("git checkout failed: %s", output)
-				}
-				return nil
-			}
-
-			args := []string{"checkout", bestMatch.name}
-			if force {
-				args = append(args, "-f")
-			}
-			return execGitCommand(args...)
-		} else {
-			// Remote branch
-			fmt.Printf("Creating local branch from remote: %s\n", bestMatch.name)
-
-			// If stash flag is set, always stash changes
-			if stash {
-				if err := stashChanges(); err != nil {
-					return fmt.Errorf("failed to stash changes: %w", err)
-				}
-			} else if !force {
-				// Only check for conflicts if not forcing and not stashing
-				output, err := execGitCommandWithOutput("checkout", "-b", bestMatch.name, "origin/"+bestMatch.name)
-				if err != nil {
-					if strings.Contains(output, "error: Your local changes to the following files would be overwritten by checkout") ||
-						strings.Contains(output, "error: The following untracked working tree files would be overwritten by checkout") {
-						// Checkout would fail, ask about stashing
-						stash, err := promptForStash()
-						if err != nil {
-							return err
-						}
-						if stash {
-							if err := stashChanges(); err != nil {
-								return fmt.Errorf("failed to stash changes: %w", err)
-							}
-							// Try the checkout again after stashing
-							return execGitCommand("checkout", "-b", bestMatch.name, "origin/"+bestMatch.name)
-						} else {
-							return errors.New("checkout aborted")
-						}
-					}
-					return fmt.Errorf("git checkout failed: %s", output)
-				}
-				return nil
-			}
-
-			args := []string{"checkout", "-b", bestMatch.name, "origin/" + bestMatch.name}
-			if force {
-				args = append(args, "-f")
-			}
-			return execGitCommand(args...)
+	if len(matches) == 1 || (len(matches) > 1 && matches[0].score > matches[1].score*2) {
+		if matches[0].branch.Kind != KindBranch {
+			return checkoutRef(matches[0].branch, opts)
 		}
+		if err := confirmGuess(matches[0].branch); err != nil {
+			return err
+		}
+		return checkoutBranch(matches[0].branch, opts)
 	} else {
 		// Multiple matches with similar scores - start interactive selector
 		fmt.Printf("Multiple matches found. Starting interactive selector...\n\n")
 
 		// Create a filtered model with only the matching branches
-		model := createFilteredBranchModel(matches, debug)
-		p := tea.NewProgram(model)
-		_, err = p.Run()
-		return err
+		model, err := createFilteredBranchModel(matches, opts)
+		if err != nil {
+			return err
+		}
+		return runSelector(model)
 	}
 }
 
-// getBranches returns a list of branches (local or remote)
-func getBranches(remote bool) ([]string, error) {
-	var args []string
-	if remote {
-		args = []string{"branch", "-r", "--format=%(refname:short)"}
-	} else {
-		args = []string{"branch", "--format=%(refname:short)"}
+// offerCreateBranch asks whether to create a branch named after a pattern
+// that matched nothing. Patterns with spaces are rendered through the branch
+// name template. Names the naming policy rejects are replaced with its
+// suggestion, or not offered at all when it has none.
+func offerCreateBranch(pattern string, opts Options) error {
+	noMatch := errors.New("no branches match '" + pattern + "'")
+	if !isInteractive() {
+		return noMatch
 	}
 
-	cmd := exec.Command("git", args...)
-	output, err := cmd.Output()
+	name, err := newBranchNameFromQuery(pattern)
 	if err != nil {
-		// If the error is due to no branches, return empty slice instead of error
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 128 {
-			return []string{}, nil
+		return noMatch
+	}
+	base := opts.From
+	if base == "" {
+		base = config().get("gch.createFrom", "HEAD")
+	}
+
+	// Offer the policy's suggestion instead of a name it would reject
+	title := fmt.Sprintf("No branches match '%s'.", pattern)
+	if err := checkNamingPolicy(name); err != nil {
+		policy, policyErr := loadNamingPolicy()
+		if policyErr != nil {
+			return policyErr
+		}
+		suggestion := policy.suggest(name)
+		if suggestion == "" {
+			return fmt.Errorf("%v\n%w", noMatch, err)
 		}
-		return nil, fmt.Errorf("failed to get branches: %w", err)
+		title += "\n" + err.Error()
+		name = suggestion
 	}
 
-	branches := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var result []string
+	choice, err := promptChoice(title, []string{
+		fmt.Sprintf("Create branch '%s' from %s", name, base),
+		"Abort",
+	})
+	if err != nil {
+		return err
+	}
+	if choice != 0 {
+		return noMatch
+	}
+	return createNewBranch(name, opts)
+}
 
-	for _, branch := range branches {
-		branch = strings.TrimSpace(branch)
-		if branch == "" {
-			continue
-		}
+// checkoutBranch checks out a single branch, creating a local tracking branch
+// for remote-only branches. Unless forcing or stashing up front, it offers to
+// deal with local changes that would be overwritten, as set by OnDirty or
+// chosen in a prompt. Submodules are handled by switchHead.
+func checkoutBranch(branch Branch, opts Options) error {
+	if err := validateOnDirty(opts.OnDirty); err != nil {
+		return err
+	}
 
-		if remote {
-			// Skip HEAD reference
-			if strings.Contains(branch, "HEAD") {
-				continue
-			}
-			// Remove the 'origin/' prefix
-			branch = strings.TrimPrefix(branch, "origin/")
+	// Branches only known from ls-remote have to be fetched first
+	if branch.Advertised {
+		if err := fetchBranch(branch); err != nil {
+			return err
 		}
+	}
 
-		result = append(result, branch)
+	if branch.Kind != KindBranch {
+		fmt.Printf("Checking out %s: %s\n", branch.Kind, branch.Name)
+	} else if branch.IsLocal {
+		fmt.Printf("Checking out local branch: %s\n", branch.Name)
+	} else {
+		fmt.Printf("Creating local branch from remote: %s\n", branch.Name)
 	}
 
-	return result, nil
+	args := checkoutArgs(branch)
+
+	return switchHead(func() error {
+		// Leaving a detached HEAD may orphan commits made on it. This runs
+		// after switchHead's guard, so a rebase or bisect in progress is
+		// reported before its commits look orphaned.
+		if err := guardOrphanedCommits(branch.Ref()); err != nil {
+			return err
+		}
+
+		// If stash flag is set, always stash changes
+		if opts.Stash {
+			return stashAndSwitch(stashOptions{}, func(top string) error { return execGitCommandIn(top, args...) })
+		} else if !opts.Force {
+			// Only check for conflicts if not forcing and not stashing
+			output, err := execGitCommandWithOutput(args...)
+			if err != nil {
+				if conflict, ok := parseCheckoutConflict(output); ok {
+					// Checkout would fail, ask what to do with the changes
+					return resolveDirty(conflict, args, opts.OnDirty)
+				}
+				return fmt.Errorf("git checkout failed: %s", output)
+			}
+			return nil
+		}
+
+		if opts.Force {
+			// Keep a copy of the changes -f throws away
+			if err := takeSnapshot("", "force checkout of "+branch.Name); err != nil {
+				return err
+			}
+			args = append(args, "-f")
+		}
+		return execGitCommand(args...)
+	})
 }
 
 // execGitCommand executes a git command with the given arguments
 func execGitCommand(args ...string) error {
+	return execGitCommandIn("", args...)
+}
+
+// execGitCommandIn executes a git command in dir, or the working directory
+// when dir is empty
+func execGitCommandIn(dir string, args ...string) error {
 	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
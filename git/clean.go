@@ -0,0 +1,354 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultProtectedBranches are never cleaned up unless gch.protected is set
+var defaultProtectedBranches = []string{"main", "master", "develop", "release/*"}
+
+// CleanOptions controls which branches `gch clean` removes
+type CleanOptions struct {
+	// DryRun lists the branches that would be deleted without deleting them
+	DryRun bool
+	// Yes deletes without asking for confirmation
+	Yes bool
+	// Force lets Yes delete stale branches that aren't merged as well
+	Force bool
+	// StaleDays also selects branches without commits for this many days.
+	// Zero disables the check.
+	StaleDays int
+	Debug     bool
+}
+
+// cleanCandidate is a local branch that can be deleted, with the reasons why
+type cleanCandidate struct {
+	branch  Branch
+	reasons []string
+	// force deletes with -D. Branches whose only reason is a gone upstream
+	// are deleted with -d, so git keeps them if their work isn't merged,
+	// e.g. when the upstream was never pushed.
+	force bool
+	// unmerged is set for stale branches whose work isn't merged. They are
+	// deleted with -D only after confirmation or with --force.
+	unmerged bool
+}
+
+// Clean finds local branches that are merged, squash-merged, have a gone
+// upstream or are stale, and deletes them after confirmation
+func Clean(opts CleanOptions) error {
+	candidates, err := findCleanCandidates(opts)
+	if err != nil {
+		return err
+	}
+
+	if len(candidates) == 0 {
+		fmt.Println("No branches to clean up")
+		return nil
+	}
+
+	if opts.DryRun {
+		fmt.Println("Would delete:")
+		for _, c := range candidates {
+			fmt.Printf("  %s (%s)\n", c.branch.Name, strings.Join(c.reasons, ", "))
+		}
+		return nil
+	}
+
+	if opts.Yes && !opts.Force {
+		var kept []cleanCandidate
+		for _, c := range candidates {
+			if c.unmerged {
+				fmt.Printf("Skipping %s: it isn't merged; pick it without --yes or pass --force\n", c.branch.Name)
+				continue
+			}
+			kept = append(kept, c)
+		}
+		candidates = kept
+	}
+
+	if !opts.Yes {
+		candidates, err = confirmCleanCandidates(candidates)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return errors.New("clean aborted")
+		}
+	}
+
+	var failed int
+	for _, c := range candidates {
+		flag := "-d"
+		if c.force {
+			flag = "-D"
+		}
+		output, err := execGitCommandWithOutput("branch", flag, c.branch.Name)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed to delete %s: %s\n", c.branch.Name, strings.TrimSpace(output))
+			continue
+		}
+		fmt.Printf("Deleted %s (was %s; %s)\n", c.branch.Name, shortHash(c.branch.Hash), strings.Join(c.reasons, ", "))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d branches", failed)
+	}
+	return nil
+}
+
+// findCleanCandidates returns the local branches that can be cleaned up. The
+// current branch, protected branches and branches checked out in a worktree
+// are never returned.
+func findCleanCandidates(opts CleanOptions) ([]cleanCandidate, error) {
+	branches, err := getAllBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultName, defaultRef, err := getDefaultBranch()
+	if err != nil && opts.Debug {
+		fmt.Printf("Skipping merge detection: %v\n", err)
+	}
+
+	protected := protectedBranches(defaultName)
+
+	checkedOut, err := worktreeBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []cleanCandidate
+	for _, b := range branches {
+		if !b.IsLocal || b.Current || checkedOut[b.Name] || isProtected(b.Name, protected) {
+			continue
+		}
+
+		var reasons []string
+		force, unmerged := false, false
+		if defaultRef != "" {
+			if isMerged(b.Name, defaultRef) {
+				reasons, force = append(reasons, "merged"), true
+			} else if isSquashMerged(b.Name, defaultRef) {
+				reasons, force = append(reasons, "squash-merged"), true
+			}
+		}
+		if b.Gone {
+			reasons = append(reasons, "upstream gone")
+		}
+		if opts.StaleDays > 0 && !b.CommitDate.IsZero() &&
+			time.Since(b.CommitDate) > time.Duration(opts.StaleDays)*24*time.Hour {
+			reasons = append(reasons, "stale "+formatAge(time.Since(b.CommitDate)))
+			if !force {
+				reasons, force, unmerged = append(reasons, "not merged, will be force-deleted"), true, true
+			}
+		}
+
+		if opts.Debug {
+			fmt.Printf("%s: %v\n", b.Name, reasons)
+		}
+		if len(reasons) > 0 {
+			candidates = append(candidates, cleanCandidate{branch: b, reasons: reasons, force: force, unmerged: unmerged})
+		}
+	}
+
+	return candidates, nil
+}
+
+// protectedBranches returns the gch.protected patterns, or the defaults,
+// along with the default branch
+func protectedBranches(defaultName string) []string {
+	protected := config().getAll("gch.protected")
+	if len(protected) == 0 {
+		protected = defaultProtectedBranches
+	}
+	if defaultName != "" {
+		protected = append(protected, defaultName)
+	}
+	return protected
+}
+
+// isProtected reports whether branch matches one of the protected patterns
+func isProtected(branch string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// worktreeBranches returns the branches checked out in any worktree
+func worktreeBranches() (map[string]bool, error) {
+	output, err := execGitCommandWithOutput("worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %s", strings.TrimSpace(output))
+	}
+
+	branches := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if ref, ok := strings.CutPrefix(line, "branch "); ok {
+			branches[strings.TrimPrefix(ref, "refs/heads/")] = true
+		}
+	}
+	return branches, nil
+}
+
+// isSquashMerged reports whether the changes of branch already landed in
+// target as a squash merge. It squashes the branch onto its merge base and
+// checks whether target contains a commit with the same patch-id, or a
+// commit whose tree is identical to the branch tip.
+func isSquashMerged(branch, target string) bool {
+	mergeBase, err := gitOutput("merge-base", target, branch)
+	if err != nil {
+		return false
+	}
+	tree, err := gitOutput("rev-parse", branch+"^{tree}")
+	if err != nil {
+		return false
+	}
+
+	// Tree comparison: the default branch reached exactly this content
+	trees, err := gitOutput("log", "--format=%T", mergeBase+".."+target)
+	if err == nil && strings.Contains("\n"+trees+"\n", "\n"+tree+"\n") {
+		return true
+	}
+
+	// Patch-id comparison via a throwaway squash commit
+	cmd := exec.Command("git", "commit-tree", tree, "-p", mergeBase, "-m", "gch squash check")
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=gch", "GIT_AUTHOR_EMAIL=gch@localhost",
+		"GIT_COMMITTER_NAME=gch", "GIT_COMMITTER_EMAIL=gch@localhost")
+	squash, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	cherry, err := gitOutput("cherry", target, strings.TrimSpace(string(squash)))
+	return err == nil && strings.HasPrefix(cherry, "-")
+}
+
+// confirmCleanCandidates shows the candidates in a checklist and returns the
+// ones the user kept selected
+func confirmCleanCandidates(candidates []cleanCandidate) ([]cleanCandidate, error) {
+	result, err := tea.NewProgram(newCleanModel(candidates)).Run()
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := result.(*cleanModel)
+	if !ok {
+		return nil, errors.New("unexpected result type from clean prompt")
+	}
+	return m.kept(), nil
+}
+
+// cleanModel is a checklist of branches to delete
+type cleanModel struct {
+	candidates []cleanCandidate
+	selected   []bool
+	cursor     int
+	confirmed  bool
+	done       bool
+}
+
+// newCleanModel returns a checklist with every candidate selected, except
+// unmerged ones, which are only deleted when the user picks them
+func newCleanModel(candidates []cleanCandidate) *cleanModel {
+	m := &cleanModel{candidates: candidates, selected: make([]bool, len(candidates))}
+	for i, c := range candidates {
+		m.selected[i] = !c.unmerged
+	}
+	return m
+}
+
+// kept returns the candidates selected when the user confirmed
+func (m *cleanModel) kept() []cleanCandidate {
+	if !m.confirmed {
+		return nil
+	}
+	var kept []cleanCandidate
+	for i, c := range m.candidates {
+		if m.selected[i] {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// Init initializes the model
+func (m *cleanModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles messages and updates the model
+func (m *cleanModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.candidates)-1 {
+				m.cursor++
+			}
+		case " ":
+			m.selected[m.cursor] = !m.selected[m.cursor]
+		case "a":
+			// Select all, or none if everything is selected already
+			all := true
+			for _, s := range m.selected {
+				all = all && s
+			}
+			for i := range m.selected {
+				m.selected[i] = !all
+			}
+		case "enter":
+			m.confirmed = true
+			m.done = true
+			return m, tea.Quit
+		case "q", "esc", "ctrl+c":
+			m.done = true
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// View renders the model
+func (m *cleanModel) View() string {
+	if m.done {
+		return ""
+	}
+
+	branches := make([]Branch, len(m.candidates))
+	for i, c := range m.candidates {
+		branches[i] = c.branch
+	}
+	rows := formatBranchRows(branches, []column{colTrack, colAge, colAuthor})
+
+	var sb strings.Builder
+	sb.WriteString("The following branches will be deleted:\n\n")
+	for i, c := range m.candidates {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		check := "[ ]"
+		if m.selected[i] {
+			check = "[x]"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s %s  (%s)\n", cursor, check, rows[i], strings.Join(c.reasons, ", ")))
+	}
+	sb.WriteString("\nSpace to toggle, a to toggle all, Enter to delete, q or Esc to abort")
+	return sb.String()
+}
@@ -0,0 +1,144 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newCleanRepo creates a repository with a branch for every case
+// findCleanCandidates tells apart
+func newCleanRepo(t *testing.T) {
+	t.Helper()
+	newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	useConfig(t, gchConfig{})
+
+	// Squash-merged after main moved on, found by patch-id
+	runGit(t, "switch", "--quiet", "-c", "squashed")
+	commitFile(t, "s.txt", "s\n")
+	commitFile(t, "s.txt", "s edited\n")
+
+	runGit(t, "switch", "--quiet", "-c", "merged", "main")
+	commitFile(t, "m.txt", "m\n")
+	runGit(t, "switch", "--quiet", "main")
+	runGit(t, "merge", "--quiet", "--no-ff", "-m", "merge", "merged")
+	runGit(t, "merge", "--quiet", "--squash", "squashed")
+	runGit(t, "commit", "--quiet", "-m", "squashed")
+
+	// Squash-merged as the last change, found by tree
+	runGit(t, "switch", "--quiet", "-c", "same-tree")
+	commitFile(t, "t.txt", "t\n")
+	runGit(t, "switch", "--quiet", "main")
+	runGit(t, "merge", "--quiet", "--squash", "same-tree")
+	runGit(t, "commit", "--quiet", "-m", "same tree")
+
+	runGit(t, "switch", "--quiet", "-c", "unmerged", "main")
+	commitFile(t, "u.txt", "u\n")
+
+	runGit(t, "switch", "--quiet", "-c", "stale", "main")
+	writeTestFile(t, "old.txt", "old\n")
+	runGit(t, "add", "old.txt")
+	commit := exec.Command("git", "commit", "--quiet", "-m", "old")
+	commit.Env = append(os.Environ(), "GIT_COMMITTER_DATE=2000-01-01T00:00:00Z")
+	if output, err := commit.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, output)
+	}
+
+	// Merged, but never candidates
+	runGit(t, "branch", "release/1.0", "merged")
+	runGit(t, "branch", "in-worktree", "merged")
+	runGit(t, "worktree", "add", "--quiet", filepath.Join(t.TempDir(), "wt"), "in-worktree")
+	runGit(t, "switch", "--quiet", "-c", "current", "merged")
+}
+
+func TestFindCleanCandidates(t *testing.T) {
+	newCleanRepo(t)
+
+	candidates, err := findCleanCandidates(CleanOptions{StaleDays: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]cleanCandidate)
+	for _, c := range candidates {
+		got[c.branch.Name] = c
+	}
+
+	for name, want := range map[string]cleanCandidate{
+		"merged":    {reasons: []string{"merged"}, force: true},
+		"squashed":  {reasons: []string{"squash-merged"}, force: true},
+		"same-tree": {reasons: []string{"squash-merged"}, force: true},
+	} {
+		c, ok := got[name]
+		if !ok {
+			t.Errorf("%s is missing", name)
+			continue
+		}
+		if !reflect.DeepEqual(c.reasons, want.reasons) || c.force != want.force || c.unmerged {
+			t.Errorf("%s = %v force=%v unmerged=%v, want %v force=%v", name, c.reasons, c.force, c.unmerged, want.reasons, want.force)
+		}
+	}
+	if c, ok := got["stale"]; !ok {
+		t.Errorf("stale is missing")
+	} else if !c.unmerged || c.reasons[len(c.reasons)-1] != "not merged, will be force-deleted" {
+		t.Errorf("stale = %v unmerged=%v, want it marked as not merged", c.reasons, c.unmerged)
+	}
+	for _, name := range []string{"unmerged", "main", "release/1.0", "in-worktree", "current"} {
+		if _, ok := got[name]; ok {
+			t.Errorf("%s is a candidate", name)
+		}
+	}
+}
+
+func TestIsSquashMerged(t *testing.T) {
+	newCleanRepo(t)
+
+	for branch, want := range map[string]bool{
+		"squashed":  true,
+		"same-tree": true,
+		"unmerged":  false,
+		"stale":     false,
+	} {
+		if got := isSquashMerged(branch, "main"); got != want {
+			t.Errorf("isSquashMerged(%s) = %v, want %v", branch, got, want)
+		}
+	}
+}
+
+func TestCleanYesKeepsUnmergedBranches(t *testing.T) {
+	newCleanRepo(t)
+
+	if err := Clean(CleanOptions{Yes: true, StaleDays: 30}); err != nil {
+		t.Fatal(err)
+	}
+	remaining := strings.Fields(runGit(t, "for-each-ref", "--format=%(refname:short)", "refs/heads"))
+	want := []string{"current", "in-worktree", "main", "release/1.0", "stale", "unmerged"}
+	if !reflect.DeepEqual(remaining, want) {
+		t.Errorf("branches = %v, want %v", remaining, want)
+	}
+
+	if err := Clean(CleanOptions{Yes: true, Force: true, StaleDays: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if localBranchExists("stale") {
+		t.Errorf("stale wasn't deleted with --force")
+	}
+}
+
+func TestCleanChecklistLeavesUnmergedBranchesUnselected(t *testing.T) {
+	candidates := []cleanCandidate{
+		{branch: Branch{Name: "merged", IsLocal: true}, reasons: []string{"merged"}, force: true},
+		{branch: Branch{Name: "stale", IsLocal: true}, reasons: []string{"stale 3mo", "not merged, will be force-deleted"}, force: true, unmerged: true},
+	}
+	m := newCleanModel(candidates)
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	kept := m.kept()
+	if len(kept) != 1 || kept[0].branch.Name != "merged" {
+		t.Errorf("Enter kept %v, want only merged", kept)
+	}
+}
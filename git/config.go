@@ -0,0 +1,153 @@
+package git
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gchConfig holds the gch.* settings from git config. Keys are stored lower
+// cased and values are kept in the order git reports them, so the last value
+// of a key has the highest precedence.
+type gchConfig map[string][]string
+
+// teamConfigFile is the committed, git-config formatted file at the root of
+// the repository that holds team-wide gch settings
+const teamConfigFile = ".gchconfig"
+
+var (
+	configOnce   sync.Once
+	loadedConfig gchConfig
+
+	userConfigOnce   sync.Once
+	loadedUserConfig gchConfig
+)
+
+// config returns the gch settings, reading them from git config on first use
+func config() gchConfig {
+	configOnce.Do(func() {
+		loadedConfig = readConfig(true)
+	})
+	return loadedConfig
+}
+
+// userConfig returns the gch settings without the team's .gchconfig. Settings
+// that decide where credentials are sent are only read from here, so a
+// committed file can't redirect them.
+func userConfig() gchConfig {
+	userConfigOnce.Do(func() {
+		loadedUserConfig = readConfig(false)
+	})
+	return loadedUserConfig
+}
+
+// readConfig reads every gch.* key visible from the current repository. The
+// layers are, from lowest to highest precedence: system and global git
+// config, the team's .gchconfig file, and the repository's own config. A key
+// set in a higher layer replaces all of its values from lower layers, so
+// teams can't have their lists silently extended by personal settings. The
+// team layer is skipped unless team is set.
+func readConfig(team bool) gchConfig {
+	layers := [][]string{{"--system"}, {"--global"}}
+	if top, err := gitOutput("rev-parse", "--show-toplevel"); err == nil && team {
+		layers = append(layers, []string{"--file", filepath.Join(top, teamConfigFile)})
+	}
+	layers = append(layers, []string{"--local"})
+
+	cfg := make(gchConfig)
+	for _, scope := range layers {
+		args := append([]string{"config", "-z", "--includes"}, scope...)
+		args = append(args, "--get-regexp", `^gch\.`)
+		output, err := exec.Command("git", args...).Output()
+		if err != nil {
+			// Exit code 1 means no gch keys are set, other errors
+			// mean the layer doesn't exist
+			continue
+		}
+
+		layer := make(gchConfig)
+		layer.parse(string(output))
+		for key, values := range layer {
+			cfg[key] = values
+		}
+	}
+	return cfg
+}
+
+// parse adds the entries of `git config -z` output to the config. Each entry
+// is "key\nvalue\x00", or just "key\x00" for a key without a value.
+func (c gchConfig) parse(output string) {
+	for _, entry := range strings.Split(output, "\x00") {
+		if entry == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(entry, "\n")
+		if !hasValue {
+			// A bare key is a boolean true
+			value = "true"
+		}
+		key = strings.ToLower(key)
+		c[key] = append(c[key], value)
+	}
+}
+
+// get returns the value of key, or def when it isn't set
+func (c gchConfig) get(key, def string) string {
+	values := c[strings.ToLower(key)]
+	if len(values) == 0 {
+		return def
+	}
+	return values[len(values)-1]
+}
+
+// getAll returns every value of a multi-valued key. Values may also be
+// given as a comma separated list.
+func (c gchConfig) getAll(key string) []string {
+	var result []string
+	for _, value := range c[strings.ToLower(key)] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+// getBool returns key interpreted as a git boolean, or def when it isn't set
+// or can't be parsed
+func (c gchConfig) getBool(key string, def bool) bool {
+	switch strings.ToLower(c.get(key, "")) {
+	case "true", "yes", "on", "1":
+		return true
+	case "false", "no", "off", "0":
+		return false
+	}
+	return def
+}
+
+// getInt returns key as an integer, or def when it isn't set or invalid
+func (c gchConfig) getInt(key string, def int) int {
+	n, err := strconv.Atoi(c.get(key, ""))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// getDuration returns key as a duration such as "10m" or "2h", or def when
+// it isn't set or invalid. A plain number is read as seconds.
+func (c gchConfig) getDuration(key string, def time.Duration) time.Duration {
+	value := c.get(key, "")
+	if n, err := strconv.Atoi(value); err == nil {
+		return time.Duration(n) * time.Second
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return def
+	}
+	return d
+}
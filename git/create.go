@@ -0,0 +1,224 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Upstream tracking policies for new branches (gch.createTrack)
+const (
+	// trackAuto leaves tracking to git's branch.autoSetupMerge, except that
+	// with push.autoSetupRemote the base isn't tracked, so the first push
+	// sets up the branch's own upstream
+	trackAuto = "auto"
+	// trackNone never sets an upstream
+	trackNone = "none"
+	// trackBase sets the base branch as upstream
+	trackBase = "base"
+	// trackRemote pushes to the base's remote and tracks the same-named
+	// branch there once it exists
+	trackRemote = "remote"
+)
+
+// baseRef is the starting point a new branch is created from
+type baseRef struct {
+	// ref is what gets passed to git, e.g. "origin/main" or "HEAD"
+	ref string
+	// remote is set when ref is a remote-tracking branch
+	remote string
+}
+
+// createNewBranch creates and checks out the branch name after checking it
+// against the naming policy. The base is taken
+// from opts.From, or gch.createFrom when no base is given, and the upstream
+// is set according to gch.createTrack.
+func createNewBranch(name string, opts Options) error {
+	if err := checkRefFormat(name); err != nil {
+		return err
+	}
+	if err := checkNamingPolicy(name); err != nil {
+		return err
+	}
+
+	from := opts.From
+	if from == "" {
+		from = config().get("gch.createFrom", "HEAD")
+	}
+
+	base, err := resolveBase(from, opts)
+	if err != nil {
+		return err
+	}
+
+	track := config().get("gch.createTrack", trackAuto)
+	trackFlag := ""
+	switch track {
+	case trackAuto:
+		if gitConfigBool("push.autoSetupRemote", false) {
+			trackFlag = "--no-track"
+		}
+	case trackNone, trackRemote:
+		trackFlag = "--no-track"
+	case trackBase:
+		trackFlag = "--track"
+	default:
+		return fmt.Errorf("invalid gch.createTrack %q (expected auto, none, base or remote)", track)
+	}
+	args := createArgs(name, base.ref, trackFlag)
+	if opts.Force {
+		args = append(args, "-f")
+	}
+
+	short, _ := gitOutput("rev-parse", "--short", base.ref)
+	fmt.Printf("Creating and checking out new branch: %s from %s (%s)\n", name, base.ref, short)
+
+	checkout := func() error {
+		// Leaving a detached HEAD may orphan commits made on it; checked
+		// after switchHead's pending-operation guard, as in checkoutBranch
+		if err := guardOrphanedCommits(base.ref); err != nil {
+			return err
+		}
+		if opts.Stash {
+			return stashAndSwitch(stashOptions{}, func(top string) error { return execGitCommandIn(top, args...) })
+		}
+		if opts.Force {
+			// Keep a copy of the changes -f throws away
+			if err := takeSnapshot("", "force creating "+name); err != nil {
+				return err
+			}
+		}
+		return execGitCommand(args...)
+	}
+	if err := switchHead(checkout); err != nil {
+		return err
+	}
+
+	if track == trackRemote {
+		remote := base.remote
+		if remote == "" {
+			remote = defaultRemote()
+		}
+		if remote != "" {
+			return setRemoteTracking(name, remote)
+		}
+	}
+	return nil
+}
+
+// setRemoteTracking makes remote the home of the new branch name. An
+// upstream is only set when the remote already has a branch of that name;
+// otherwise one that doesn't exist would show up as gone right away, so only
+// the push remote is set and the first push creates the branch.
+func setRemoteTracking(name, remote string) error {
+	if _, err := gitOutput("rev-parse", "--verify", "--quiet", "refs/remotes/"+remote+"/"+name); err == nil {
+		return execGitCommand("branch", "--quiet", "--set-upstream-to", remote+"/"+name, name)
+	}
+	if err := execGitCommand("config", "branch."+name+".pushRemote", remote); err != nil {
+		return err
+	}
+	if !gitConfigBool("push.autoSetupRemote", false) {
+		fmt.Printf("Push with `git push -u %s %s` to set its upstream\n", remote, name)
+	}
+	return nil
+}
+
+// resolveBase resolves the base a new branch starts from. Besides fuzzy
+// branch patterns it accepts the navigation aliases, "HEAD", "default" for
+// the remote's default branch and any other revision git understands.
+// Remote-tracking bases are fetched first unless offline.
+func resolveBase(pattern string, opts Options) (baseRef, error) {
+	if pattern == "HEAD" || pattern == "@" {
+		return baseRef{ref: "HEAD"}, nil
+	}
+	// Full refs and object IDs, e.g. a tag picked in the selector, are
+	// never fuzzy matched
+	if strings.HasPrefix(pattern, "refs/") || objectID.MatchString(pattern) {
+		if _, err := gitOutput("rev-parse", "--verify", "--quiet", pattern+"^{commit}"); err != nil {
+			return baseRef{}, fmt.Errorf("'%s' is not a commit", pattern)
+		}
+		return baseRef{ref: pattern}, nil
+	}
+	if pattern == "default" || pattern == aliasDefault2 {
+		pattern = aliasDefault
+	}
+
+	var base Branch
+	if isAlias(pattern) {
+		b, err := resolveAlias(pattern)
+		if err != nil {
+			return baseRef{}, err
+		}
+		// Branch from the remote's state of the default branch, not a
+		// possibly outdated local copy
+		if pattern == aliasDefault {
+			if remote := defaultRemote(); remote != "" && !opts.Offline {
+				b = Branch{Name: b.Name, Remote: remote}
+			}
+		}
+		base = b
+	} else {
+		b, err := matchBaseBranch(pattern)
+		if err != nil {
+			// Fall back to tags, commits and other revisions
+			if _, revErr := gitOutput("rev-parse", "--verify", "--quiet", pattern+"^{commit}"); revErr == nil {
+				return baseRef{ref: pattern}, nil
+			}
+			return baseRef{}, err
+		}
+		base = b
+	}
+
+	if base.IsLocal {
+		return baseRef{ref: base.Name}, nil
+	}
+
+	if !opts.Offline {
+		if err := fetchBranch(base); err != nil {
+			if base.Advertised {
+				return baseRef{}, err
+			}
+			fmt.Printf("Warning: %v, using %s as last fetched\n", err, base.RemoteRef())
+		}
+	}
+	return baseRef{ref: base.RemoteRef(), remote: base.Remote}, nil
+}
+
+// matchBaseBranch finds the single branch pattern refers to, using the same
+// scoring as checkout. Ambiguous patterns are an error listing the
+// candidates, since there is no selector to pick from.
+func matchBaseBranch(pattern string) (Branch, error) {
+	branches, err := getAllBranches()
+	if err != nil {
+		return Branch{}, err
+	}
+
+	var matches []branchMatch
+	for _, b := range branches {
+		if score := calcMatchScore(b.Name, pattern); score > 0 {
+			matches = append(matches, branchMatch{branch: b, score: score})
+		}
+		// Allow naming a remote branch explicitly, e.g. "upstream/main"
+		if !b.IsLocal && b.RemoteRef() == pattern {
+			return b, nil
+		}
+	}
+	if len(matches) == 0 {
+		return Branch{}, errors.New("no base branch matches '" + pattern + "'")
+	}
+
+	sortMatches(matches)
+	if len(matches) == 1 || matches[0].score > matches[1].score*2 {
+		return matches[0].branch, nil
+	}
+
+	var names []string
+	for i, m := range matches {
+		if i == 5 {
+			names = append(names, "...")
+			break
+		}
+		names = append(names, m.branch.Name)
+	}
+	return Branch{}, fmt.Errorf("base '%s' is ambiguous: %s", pattern, strings.Join(names, ", "))
+}
@@ -0,0 +1,105 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxOrphansShown limits the commits listed when leaving a detached HEAD
+const maxOrphansShown = 5
+
+// describeDetached describes a detached HEAD for the selector header: the
+// commit and its subject, and the nearest tag with the number of commits
+// since. It returns an empty string when HEAD is on a branch.
+func describeDetached() string {
+	if current, err := getCurrentBranch(); err != nil || current != "" {
+		return ""
+	}
+	commit, err := gitOutput("log", "-1", "--format=%h %s", "HEAD")
+	if err != nil {
+		return ""
+	}
+
+	header := "HEAD detached at " + commit
+	if tag, err := gitOutput("describe", "--tags", "--abbrev=0", "HEAD"); err == nil {
+		if count, _ := gitOutput("rev-list", "--count", tag+"..HEAD"); count != "" && count != "0" {
+			header += fmt.Sprintf(" (%s +%s)", tag, count)
+		} else {
+			header += fmt.Sprintf(" (%s)", tag)
+		}
+	}
+	return header
+}
+
+// orphanedCommits returns the commits of a detached HEAD that no branch, tag
+// or remote-tracking branch contains and that target doesn't contain either,
+// newest first, as "hash subject" lines
+func orphanedCommits(target string) []string {
+	if current, err := getCurrentBranch(); err != nil || current != "" {
+		return nil
+	}
+	args := []string{"log", "--format=%h %s", "HEAD", "--not", "--branches", "--tags", "--remotes"}
+	if target != "" {
+		args = append(args, target)
+	}
+	output, err := gitOutput(args...)
+	if err != nil || output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
+
+// guardOrphanedCommits warns before leaving a detached HEAD for target when
+// that would leave commits behind that only the reflog still knows, and
+// offers to create a branch for them first
+func guardOrphanedCommits(target string) error {
+	commits := orphanedCommits(target)
+	if len(commits) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Warning: you are leaving %d commit(s) behind that are not on any branch:\n", len(commits))
+	for i, commit := range commits {
+		if i == maxOrphansShown {
+			fmt.Printf("  ... and %d more\n", len(commits)-maxOrphansShown)
+			break
+		}
+		fmt.Printf("  %s\n", commit)
+	}
+
+	head, _ := gitOutput("rev-parse", "--short", "HEAD")
+	if !isInteractive() {
+		fmt.Printf("Create a branch for them with `git branch <name> %s`.\n", head)
+		return nil
+	}
+
+	choice, err := promptChoice("Keep these commits?", []string{
+		"Create a branch for them, then switch",
+		"Leave them behind and switch",
+		"Abort",
+	})
+	if err != nil {
+		return err
+	}
+	switch choice {
+	case 0:
+		name, err := promptText("Name of the new branch:", "")
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return errors.New("checkout aborted")
+		}
+		if err := execGitCommand("branch", name, "HEAD"); err != nil {
+			return err
+		}
+		fmt.Printf("Created branch %s at %s\n", name, head)
+		return nil
+	case 1:
+		fmt.Printf("Recover them later with `git branch <name> %s`.\n", head)
+		return nil
+	default:
+		return errors.New("checkout aborted")
+	}
+}
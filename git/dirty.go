@@ -0,0 +1,279 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Ways to deal with local changes in the way of a checkout (--on-dirty)
+const (
+	// dirtyStash stashes the changes, with only the untracked files that are
+	// in the way
+	dirtyStash = "stash"
+	// dirtyStashAll stashes the changes with all untracked files
+	dirtyStashAll = "stash-all"
+	// dirtyMerge carries the changes over with checkout --merge
+	dirtyMerge = "merge"
+	// dirtyWIP commits the changes on the current branch, to be restored
+	// when gch checks it out again
+	dirtyWIP = "wip"
+	// dirtyDiscard throws the changes away
+	dirtyDiscard = "discard"
+	// dirtyAbort leaves everything as it is
+	dirtyAbort = "abort"
+)
+
+// dirtyActions lists the valid --on-dirty values
+var dirtyActions = []string{dirtyStash, dirtyStashAll, dirtyMerge, dirtyWIP, dirtyDiscard, dirtyAbort}
+
+// wipPrefix starts the subject of gch's work-in-progress commits, followed
+// by the branch they were made on
+const wipPrefix = "gch WIP on "
+
+// dirtyChoice is one way of dealing with a checkout conflict
+type dirtyChoice struct {
+	label  string
+	action string
+	stash  stashOptions
+}
+
+// validateOnDirty checks the value of --on-dirty
+func validateOnDirty(action string) error {
+	if action == "" {
+		return nil
+	}
+	for _, valid := range dirtyActions {
+		if action == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --on-dirty %q (expected %s)", action, strings.Join(dirtyActions, ", "))
+}
+
+// dirtyChoices returns the ways of dealing with conflict. Stashing and
+// discarding work for every conflict, merging only when no untracked files
+// are in the way. Abort is always last.
+func dirtyChoices(conflict checkoutConflict) []dirtyChoice {
+	var choices []dirtyChoice
+	if conflict.untracked {
+		choices = append(choices,
+			dirtyChoice{label: "Stash changes and only the conflicting untracked files", action: dirtyStash, stash: conflictStash(conflict)},
+			dirtyChoice{label: "Stash changes including all untracked files (git stash -u)", action: dirtyStashAll, stash: stashOptions{untracked: true}},
+		)
+	} else {
+		// git checkout --merge can't move untracked files out of the way
+		choices = append(choices,
+			dirtyChoice{label: "Stash changes and continue", action: dirtyStash},
+			dirtyChoice{label: "Stash changes including all untracked files (git stash -u)", action: dirtyStashAll, stash: stashOptions{untracked: true}},
+			dirtyChoice{label: "Carry changes over to the other branch (git checkout --merge)", action: dirtyMerge},
+		)
+	}
+	if branch, err := gitOutput("symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		choices = append(choices, dirtyChoice{
+			label:  fmt.Sprintf("Commit changes as WIP on %s, restored when you come back", branch),
+			action: dirtyWIP,
+		})
+	}
+	return append(choices,
+		dirtyChoice{label: "Discard changes", action: dirtyDiscard},
+		dirtyChoice{label: "Abort checkout", action: dirtyAbort},
+	)
+}
+
+// resolveDirty deals with the local changes in the way of the checkout
+// args, as chosen with --on-dirty or in a prompt, and runs the checkout
+func resolveDirty(conflict checkoutConflict, args []string, onDirty string) error {
+	choices := dirtyChoices(conflict)
+
+	var choice dirtyChoice
+	switch {
+	case onDirty != "":
+		found := false
+		for _, c := range choices {
+			if c.action == onDirty {
+				choice, found = c, true
+			}
+		}
+		if !found && onDirty == dirtyMerge {
+			return errors.New("--on-dirty=merge can't move untracked files out of the way; use --on-dirty=stash")
+		}
+		if !found {
+			return fmt.Errorf("--on-dirty=%s can't resolve this conflict", onDirty)
+		}
+	case !isInteractive():
+		return fmt.Errorf("local changes would be overwritten by checkout; use --on-dirty=%s",
+			strings.Join(dirtyActions, "|"))
+	default:
+		var err error
+		if choice, err = promptForStash(conflict, choices); err != nil {
+			return err
+		}
+		// Discarding is the one choice that can't be taken back
+		if choice.action == dirtyDiscard {
+			confirm, err := promptChoice("Discard all local changes? This can't be undone.", []string{
+				"Keep them and abort",
+				"Discard them",
+			})
+			if err != nil {
+				return err
+			}
+			if confirm != 1 {
+				choice.action = dirtyAbort
+			}
+		}
+	}
+
+	switch choice.action {
+	case dirtyStash, dirtyStashAll:
+		// Try the checkout again after stashing
+		return stashAndSwitch(choice.stash, func(top string) error { return execGitCommandIn(top, args...) })
+	case dirtyMerge:
+		return execGitCommand(append(args, "--merge")...)
+	case dirtyWIP:
+		return commitWIPAndSwitch(conflict, args)
+	case dirtyDiscard:
+		if err := takeSnapshot("", "discarding changes"); err != nil {
+			return err
+		}
+		return execGitCommand(append(args, "--force")...)
+	default:
+		return errors.New("checkout aborted")
+	}
+}
+
+// wipCommit is a WIP commit gch made, kept in .git/gch/wip.json by branch
+type wipCommit struct {
+	Commit string `json:"commit"`
+	// Index is the tree of the index before the commit, so that the staged
+	// changes can be told apart from the unstaged ones again. It is empty
+	// when the index couldn't be written as a tree.
+	Index string `json:"index,omitempty"`
+}
+
+// wipPath returns the path of the record of WIP commits
+func wipPath() (string, error) {
+	dir, err := gchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wip.json"), nil
+}
+
+// readWIPCommits returns the recorded WIP commits by branch
+func readWIPCommits() map[string]wipCommit {
+	commits := make(map[string]wipCommit)
+	path, err := wipPath()
+	if err != nil {
+		return commits
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &commits)
+	}
+	return commits
+}
+
+// writeWIPCommits writes the record of WIP commits
+func writeWIPCommits(commits map[string]wipCommit) error {
+	path, err := wipPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(commits)
+	if err != nil {
+		return err
+	}
+	return writeStateFile(path, data)
+}
+
+// undoWIPCommit resets the branch to before the WIP commit, keeping its
+// changes in the working tree, and brings back the index as it was
+func undoWIPCommit(wip wipCommit) error {
+	if err := execGitCommand("reset", "--quiet", "HEAD^"); err != nil {
+		return err
+	}
+	if wip.Index != "" {
+		return execGitCommand("read-tree", wip.Index)
+	}
+	return nil
+}
+
+// commitWIPAndSwitch commits the local changes, including the untracked files
+// in the way, as a WIP commit on the current branch and runs the checkout. If
+// the checkout fails the commit is undone again. The commit is recorded so
+// that restoreWIP only ever undoes a commit gch made.
+func commitWIPAndSwitch(conflict checkoutConflict, args []string) error {
+	branch, err := gitOutput("symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return errors.New("can't commit work in progress on a detached HEAD")
+	}
+
+	// The commit has the staged and unstaged changes together; the index is
+	// saved as a tree to separate them again on restore
+	index, _ := gitOutput("write-tree")
+	if err := execGitCommand("add", "--update", ":/"); err != nil {
+		return err
+	}
+	if conflict.untracked {
+		if err := execGitCommand(append([]string{"add", "--"}, topPaths(conflict.files)...)...); err != nil {
+			return err
+		}
+	}
+	if err := execGitCommand("commit", "--quiet", "--no-verify", "-m", wipPrefix+branch); err != nil {
+		return fmt.Errorf("failed to commit work in progress: %w", err)
+	}
+	commit, err := gitOutput("rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	wip := wipCommit{Commit: commit, Index: index}
+	fmt.Printf("Committed your changes as WIP on %s\n", branch)
+
+	if err := execGitCommand(args...); err != nil {
+		fmt.Println("Checkout failed, restoring your changes")
+		if resetErr := undoWIPCommit(wip); resetErr != nil {
+			return fmt.Errorf("checkout failed (%v) and undoing the WIP commit failed too: %w", err, resetErr)
+		}
+		return err
+	}
+
+	commits := readWIPCommits()
+	commits[branch] = wip
+	if err := writeWIPCommits(commits); err != nil {
+		fmt.Printf("warning: failed to record the WIP commit, undo it with 'git reset HEAD^' on %s: %v\n", branch, err)
+	}
+	return nil
+}
+
+// restoreWIP undoes the WIP commit gch recorded for the current branch,
+// bringing back the changes it saved. It only does so while the commit is
+// still the tip of the branch and hasn't been pushed to its upstream.
+func restoreWIP() error {
+	branch, err := gitOutput("symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return nil
+	}
+	commits := readWIPCommits()
+	wip, ok := commits[branch]
+	if !ok {
+		return nil
+	}
+	head, err := gitOutput("rev-parse", "HEAD")
+	if err != nil || head != wip.Commit {
+		return nil
+	}
+	if _, err := gitOutput("merge-base", "--is-ancestor", "HEAD", "@{upstream}"); err == nil {
+		// Pushed, so undoing it would rewrite published history
+		return nil
+	}
+
+	fmt.Printf("Restoring the work in progress saved on %s\n", branch)
+	if err := undoWIPCommit(wip); err != nil {
+		return err
+	}
+	delete(commits, branch)
+	return writeWIPCommits(commits)
+}
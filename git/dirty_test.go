@@ -0,0 +1,66 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestRestoreWIP(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare runs on other after the WIP commit, before main is checked
+		// out again
+		prepare     func(t *testing.T)
+		wantRestore bool
+	}{
+		{"recorded WIP commit", func(*testing.T) {}, true},
+		{"pushed to the upstream", func(t *testing.T) {
+			runGit(t, "remote", "add", "origin", ".")
+			runGit(t, "update-ref", "refs/remotes/origin/main", "main")
+			runGit(t, "branch", "--quiet", "--set-upstream-to=origin/main", "main")
+		}, false},
+		{"a commit gch didn't record", func(t *testing.T) {
+			// Same subject as the WIP commit, but made by someone else
+			runGit(t, "checkout", "--quiet", "main")
+			runGit(t, "reset", "--quiet", "HEAD^")
+			runGit(t, "add", "a.txt")
+			runGit(t, "commit", "--quiet", "-m", wipPrefix+"main")
+			runGit(t, "checkout", "--quiet", "other")
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+			runGit(t, "branch", "other")
+			writeTestFile(t, "a.txt", "a staged\n")
+			runGit(t, "add", "a.txt")
+			writeTestFile(t, "b.txt", "b unstaged\n")
+
+			if err := commitWIPAndSwitch(checkoutConflict{}, []string{"checkout", "--quiet", "other"}); err != nil {
+				t.Fatal(err)
+			}
+			wip := runGit(t, "rev-parse", "main")
+			tt.prepare(t)
+			runGit(t, "checkout", "--quiet", "main")
+			if err := restoreWIP(); err != nil {
+				t.Fatal(err)
+			}
+
+			restored := runGit(t, "rev-parse", "HEAD") != wip && runGit(t, "log", "-1", "--format=%s") == "initial"
+			if restored != tt.wantRestore {
+				t.Fatalf("restored = %v, want %v", restored, tt.wantRestore)
+			}
+			if !restored {
+				return
+			}
+			if got := runGit(t, "diff", "--cached", "--name-only"); got != "a.txt" {
+				t.Errorf("staged = %q, want a.txt", got)
+			}
+			if got := runGit(t, "diff", "--name-only"); got != "b.txt" {
+				t.Errorf("unstaged = %q, want b.txt", got)
+			}
+			if _, ok := readWIPCommits()["main"]; ok {
+				t.Error("WIP commit still recorded after restoring it")
+			}
+		})
+	}
+}
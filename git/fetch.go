@@ -0,0 +1,105 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultFetchTTL is how long a fetch is considered fresh when gch.fetchTTL
+// isn't set
+const defaultFetchTTL = 5 * time.Minute
+
+// fetchDoneMsg is sent when a background fetch finishes
+type fetchDoneMsg struct {
+	err    error
+	output string
+}
+
+// shouldFetch reports whether the selector should fetch in the background.
+// Fetches are skipped offline, without remotes, and when the last fetch is
+// younger than gch.fetchTTL.
+func shouldFetch(offline bool) bool {
+	if offline || defaultRemote() == "" {
+		return false
+	}
+	ttl := config().getDuration("gch.fetchTTL", defaultFetchTTL)
+	last, ok := lastFetchTime()
+	return !ok || time.Since(last) >= ttl
+}
+
+// lastFetchTime returns when gch last fetched all remotes. FETCH_HEAD can't
+// tell, since fetching a single branch rewrites it too.
+func lastFetchTime() (time.Time, bool) {
+	path, err := lastFetchPath()
+	if err != nil {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// lastFetchPath returns the path of the file whose modification time is the
+// time of the last full fetch
+func lastFetchPath() (string, error) {
+	dir, err := gchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "last-fetch"), nil
+}
+
+// backgroundFetch is a fetch of all remotes that runs while the selector is
+// shown
+type backgroundFetch struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	result fetchDoneMsg
+}
+
+// startFetch starts fetching all remotes without writing to the terminal
+func startFetch() *backgroundFetch {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &backgroundFetch{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		cmd := exec.CommandContext(ctx, "git", "fetch", "--all", "--quiet")
+		// Never let a credential prompt take over the selector
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		// Let git remove its lock files when it is cancelled
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+		cmd.WaitDelay = 5 * time.Second
+		output, err := cmd.CombinedOutput()
+		f.result = fetchDoneMsg{err: err, output: strings.TrimSpace(string(output))}
+		if err == nil {
+			if path, err := lastFetchPath(); err == nil {
+				_ = writeStateFile(path, nil)
+			}
+		}
+	}()
+	return f
+}
+
+// wait returns a command that reports the result of the fetch as a
+// fetchDoneMsg once it finishes
+func (f *backgroundFetch) wait() tea.Cmd {
+	return func() tea.Msg {
+		<-f.done
+		return f.result
+	}
+}
+
+// stop cancels the fetch if it is still running and waits for git to exit,
+// so it never races a checkout or outlives gch
+func (f *backgroundFetch) stop() {
+	f.cancel()
+	<-f.done
+}
@@ -0,0 +1,286 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultForgeCacheTTL is how long cached pull requests are shown without
+// refreshing them when gch.forge.cacheTTL isn't set
+const defaultForgeCacheTTL = 5 * time.Minute
+
+// Review states of a pull request
+const (
+	reviewApproved = "approved"
+	reviewChanges  = "changes requested"
+	reviewRequired = "review required"
+	reviewDraft    = "draft"
+)
+
+// CI states of a pull request's head commit
+const (
+	ciPassing = "passing"
+	ciFailing = "failing"
+	ciPending = "pending"
+)
+
+// PullRequest is an open pull or merge request on the forge
+type PullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	// Branch is the head branch, empty for pull requests from forks
+	Branch string `json:"branch"`
+	Review string `json:"review"`
+	CI     string `json:"ci"`
+	URL    string `json:"url"`
+}
+
+// badge returns the short summary shown next to a branch in the selector
+func (pr PullRequest) badge() string {
+	var parts []string
+	if pr.Review != "" {
+		parts = append(parts, pr.Review)
+	}
+	if pr.CI != "" {
+		parts = append(parts, "CI "+pr.CI)
+	}
+
+	title := []rune(pr.Title)
+	if len(title) > 40 {
+		title = append(title[:39], '…')
+	}
+	badge := "#" + strconv.Itoa(pr.Number)
+	if len(parts) > 0 {
+		badge += " " + strings.Join(parts, ", ")
+	}
+	return "[" + badge + "] " + string(title)
+}
+
+// forgeProvider looks up the open pull requests of a repository on a forge
+type forgeProvider interface {
+	pullRequests() ([]PullRequest, error)
+}
+
+// forgeCache is the on-disk cache of pull requests in .git/gch/forge.json
+type forgeCache struct {
+	Fetched      time.Time     `json:"fetched"`
+	Project      string        `json:"project"`
+	PullRequests []PullRequest `json:"pullRequests"`
+}
+
+// pullRequestsMsg is sent when pull requests were refreshed in the background
+type pullRequestsMsg struct {
+	pulls []PullRequest
+	err   error
+}
+
+// remoteURLPattern splits scp-like and URL style remote URLs into host and
+// project path, e.g. "git@github.com:owner/repo.git"
+var remoteURLPattern = regexp.MustCompile(`^(?:[a-z+]+://)?(?:[^@/]+@)?([^/:]+)(?::\d+)?[:/](.+?)(?:\.git)?/?$`)
+
+// trustedAPIHosts are the forge APIs tokens are sent to without the user
+// configuring them
+var trustedAPIHosts = map[string]bool{"api.github.com": true, "gitlab.com": true}
+
+// forgeSettings returns the provider kind, project and API base URL from
+// gch.forge.* and the remote's URL, or an empty kind when no forge is
+// configured. They are never read from .gchconfig, see userConfig.
+func forgeSettings() (kind, project, baseURL string, err error) {
+	cfg := userConfig()
+	kind = strings.ToLower(cfg.get("gch.forge.provider", ""))
+	if kind == "" || kind == "none" {
+		return "", "", "", nil
+	}
+
+	var host string
+	remote := config().get("gch.prRemote", defaultRemote())
+	if remoteURL, err := gitOutput("remote", "get-url", remote); err == nil {
+		if m := remoteURLPattern.FindStringSubmatch(remoteURL); m != nil {
+			host, project = m[1], m[2]
+		}
+	}
+	project = cfg.get("gch.forge.project", project)
+
+	if kind == "auto" {
+		switch {
+		case host == "github.com":
+			kind = "github"
+		case strings.Contains(host, "gitlab"):
+			kind = "gitlab"
+		default:
+			return "", "", "", fmt.Errorf("can't detect the forge of %s; set gch.forge.provider to github or gitlab", remote)
+		}
+	}
+
+	switch kind {
+	case "github":
+		baseURL = "https://api.github.com"
+		if host != "" && host != "github.com" {
+			baseURL = "https://" + host + "/api/v3"
+		}
+	case "gitlab":
+		baseURL = "https://gitlab.com/api/v4"
+		if host != "" {
+			baseURL = "https://" + host + "/api/v4"
+		}
+	default:
+		return "", "", "", fmt.Errorf("invalid gch.forge.provider %q (expected github, gitlab, auto or none)", kind)
+	}
+	baseURL = strings.TrimRight(cfg.get("gch.forge.url", baseURL), "/")
+
+	if project == "" {
+		return "", "", "", fmt.Errorf("can't tell the project from the URL of %s; set gch.forge.project", remote)
+	}
+	return kind, project, baseURL, nil
+}
+
+// sendToken reports whether the forge token may be sent to baseURL: only to
+// github.com and gitlab.com, or to the API the user set as gch.forge.url.
+// Other hosts, like one taken from a remote's URL, get anonymous requests.
+func sendToken(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "https" && trustedAPIHosts[u.Host] {
+		return true
+	}
+	configured, err := url.Parse(userConfig().get("gch.forge.url", ""))
+	return err == nil && configured.Host != "" && configured.Scheme == u.Scheme && configured.Host == u.Host
+}
+
+// newForgeProvider returns the provider configured with gch.forge.provider,
+// or nil when no forge is configured
+func newForgeProvider() (forgeProvider, string, error) {
+	kind, project, baseURL, err := forgeSettings()
+	if err != nil || kind == "" {
+		return nil, "", err
+	}
+
+	var token string
+	switch kind {
+	case "github":
+		if token = os.Getenv("GITHUB_TOKEN"); token == "" {
+			token = os.Getenv("GH_TOKEN")
+		}
+	default:
+		token = os.Getenv("GITLAB_TOKEN")
+	}
+	if !sendToken(baseURL) {
+		token = ""
+	}
+
+	switch kind {
+	case "github":
+		return githubProvider{baseURL: baseURL, project: project, token: token}, project, nil
+	default:
+		return gitlabProvider{baseURL: baseURL, project: project, token: token}, project, nil
+	}
+}
+
+// forgeCachePath returns the path of the pull request cache
+func forgeCachePath() (string, error) {
+	dir, err := gchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "forge.json"), nil
+}
+
+// cachedPullRequests returns the cached pull requests of project and
+// whether they are younger than gch.forge.cacheTTL
+func cachedPullRequests(project string) ([]PullRequest, bool) {
+	path, err := forgeCachePath()
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var cache forgeCache
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Project != project {
+		return nil, false
+	}
+	ttl := config().getDuration("gch.forge.cacheTTL", defaultForgeCacheTTL)
+	return cache.PullRequests, time.Since(cache.Fetched) < ttl
+}
+
+// refreshPullRequests asks the provider for the open pull requests and
+// writes them to the cache. While the forge is rate limiting gch, the
+// cached pull requests are returned instead, however old.
+func refreshPullRequests(provider forgeProvider, project string) ([]PullRequest, error) {
+	pulls, err := provider.pullRequests()
+	if isRateLimited(err) {
+		if cached, _ := cachedPullRequests(project); cached != nil {
+			return cached, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if path, err := forgeCachePath(); err == nil {
+		data, err := json.Marshal(forgeCache{Fetched: time.Now(), Project: project, PullRequests: pulls})
+		if err == nil {
+			_ = writeStateFile(path, data)
+		}
+	}
+	return pulls, nil
+}
+
+// loadPullRequests returns the cached pull requests and whether they need
+// refreshing. Nothing is refreshed when offline or no forge is configured.
+func loadPullRequests(offline bool) (pulls []PullRequest, stale bool) {
+	provider, project, err := newForgeProvider()
+	if err != nil || provider == nil {
+		return nil, false
+	}
+	pulls, fresh := cachedPullRequests(project)
+	return pulls, !fresh && !offline
+}
+
+// refreshForgeCache refreshes the pull requests of the configured forge
+func refreshForgeCache() ([]PullRequest, error) {
+	provider, project, err := newForgeProvider()
+	if err != nil || provider == nil {
+		return nil, err
+	}
+	return refreshPullRequests(provider, project)
+}
+
+// fetchPullRequests returns a command that refreshes the pull requests in
+// the background and reports them as a pullRequestsMsg
+func fetchPullRequests() tea.Cmd {
+	return func() tea.Msg {
+		pulls, err := refreshForgeCache()
+		return pullRequestsMsg{pulls: pulls, err: err}
+	}
+}
+
+// attachPullRequests links branches to their open pull requests: branches
+// named like the pull request's head branch, and pr/<number> branches
+// created by `gch pr`
+func attachPullRequests(branches []Branch, pulls []PullRequest) {
+	byBranch := make(map[string]*PullRequest, len(pulls)*2)
+	for i := range pulls {
+		pr := &pulls[i]
+		if pr.Branch != "" {
+			byBranch[pr.Branch] = pr
+		}
+		byBranch[prBranchName(pr.Number)] = pr
+	}
+	for i := range branches {
+		if branches[i].Kind == KindBranch {
+			branches[i].PR = byBranch[branches[i].Name]
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package git
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeJSON writes v as the response
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGitHubPullRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch r.URL.Path {
+		case "/repos/owner/repo/pulls":
+			writeJSON(t, w, []map[string]any{
+				{"number": 1, "title": "Add login", "html_url": "https://github.com/owner/repo/pull/1",
+					"head": map[string]any{"ref": "feat/login", "repo": map[string]any{"full_name": "owner/repo"}}},
+				{"number": 2, "title": "From a fork", "requested_reviewers": []map[string]any{{"login": "someone"}},
+					"head": map[string]any{"ref": "main", "repo": map[string]any{"full_name": "someone/repo"}}},
+				{"number": 3, "title": "Work in progress", "draft": true,
+					"head": map[string]any{"ref": "wip", "repo": map[string]any{"full_name": "owner/repo"}}},
+				{"number": 4, "title": "Nobody asked",
+					"head": map[string]any{"ref": "quiet", "repo": map[string]any{"full_name": "owner/repo"}}},
+			})
+		case "/search/issues":
+			if q := r.URL.Query().Get("q"); q != "repo:owner/repo is:pr is:open review:approved" {
+				t.Errorf("q = %q", q)
+			}
+			writeJSON(t, w, map[string]any{"items": []map[string]int{{"number": 1}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := githubProvider{baseURL: server.URL, project: "owner/repo", token: "secret"}
+	pulls, err := provider.pullRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PullRequest{
+		{Number: 1, Title: "Add login", Branch: "feat/login", Review: reviewApproved, URL: "https://github.com/owner/repo/pull/1"},
+		{Number: 2, Title: "From a fork", Review: reviewRequired},
+		{Number: 3, Title: "Work in progress", Branch: "wip", Review: reviewDraft},
+		{Number: 4, Title: "Nobody asked", Branch: "quiet"},
+	}
+	if !reflect.DeepEqual(pulls, want) {
+		t.Errorf("pullRequests() = %+v, want %+v", pulls, want)
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+}
+
+func TestGitLabPullRequests(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fsub%2Frepo/merge_requests":
+			if r.URL.Query().Get("page") != "1" {
+				writeJSON(t, w, []any{})
+				return
+			}
+			mrs := []map[string]any{
+				{"iid": 7, "title": "Add login", "web_url": "https://gitlab.com/group/sub/repo/-/merge_requests/7",
+					"source_branch": "feat/login", "source_project_id": 1, "target_project_id": 1,
+					"sha": "aaa", "detailed_merge_status": "mergeable"},
+				{"iid": 8, "title": "From a fork", "source_branch": "main", "source_project_id": 2, "target_project_id": 1,
+					"sha": "bbb", "detailed_merge_status": "not_approved"},
+				{"iid": 9, "title": "Work in progress", "draft": true, "source_branch": "wip", "source_project_id": 1, "target_project_id": 1,
+					"sha": "ccc", "detailed_merge_status": "draft_status"},
+			}
+			// Fill the first page so the second one is asked for
+			for i := len(mrs); i < 100; i++ {
+				mrs = append(mrs, map[string]any{"iid": 100 + i, "sha": "none", "detailed_merge_status": "ci_must_pass"})
+			}
+			writeJSON(t, w, mrs)
+		case "/api/v4/projects/group%2Fsub%2Frepo/pipelines":
+			writeJSON(t, w, []map[string]any{
+				{"sha": "aaa", "status": "success"},
+				{"sha": "bbb", "status": "failed"},
+				{"sha": "ccc", "status": "running"},
+				{"sha": "aaa", "status": "failed"},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := gitlabProvider{baseURL: server.URL + "/api/v4", project: "group/sub/repo"}
+	pulls, err := provider.pullRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pulls) != 100 {
+		t.Fatalf("got %d merge requests, want 100", len(pulls))
+	}
+	want := []PullRequest{
+		{Number: 7, Title: "Add login", Branch: "feat/login", Review: reviewApproved, CI: ciPassing, URL: "https://gitlab.com/group/sub/repo/-/merge_requests/7"},
+		{Number: 8, Title: "From a fork", Review: reviewRequired, CI: ciFailing},
+		{Number: 9, Title: "Work in progress", Branch: "wip", Review: reviewDraft, CI: ciPending},
+		{Number: 103},
+	}
+	if got := append(pulls[:3:3], pulls[3]); !reflect.DeepEqual(got, want) {
+		t.Errorf("pullRequests() = %+v, want %+v", got, want)
+	}
+	if len(paths) != 3 {
+		t.Errorf("requests = %v, want two pages and the pipelines", paths)
+	}
+}
+
+func TestRateLimitedRefreshServesCache(t *testing.T) {
+	newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	useConfig(t, gchConfig{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limit exceeded", http.StatusForbidden)
+	}))
+	defer server.Close()
+	provider := githubProvider{baseURL: server.URL, project: "owner/repo"}
+
+	if _, err := refreshPullRequests(provider, "owner/repo"); err == nil {
+		t.Fatal("expected an error without a cache to fall back to")
+	}
+
+	cached := stubProvider{{Number: 1, Title: "Add login"}}
+	if _, err := refreshPullRequests(cached, "owner/repo"); err != nil {
+		t.Fatal(err)
+	}
+	pulls, err := refreshPullRequests(provider, "owner/repo")
+	if err != nil || !reflect.DeepEqual(pulls, []PullRequest(cached)) {
+		t.Errorf("refreshPullRequests() = %+v, %v, want the cached pull requests", pulls, err)
+	}
+}
+
+func TestSendToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		baseURL    string
+		want       bool
+	}{
+		{"github.com", "", "https://api.github.com", true},
+		{"gitlab.com", "", "https://gitlab.com/api/v4", true},
+		{"plain http to a trusted host", "", "http://api.github.com", false},
+		{"host from a remote URL", "", "https://git.example.com/api/v4", false},
+		{"configured host", "https://git.example.com/api/v4", "https://git.example.com/api/v4", true},
+		{"configured host over http", "http://127.0.0.1:8080", "http://127.0.0.1:8080/api/v3", true},
+		{"other port than configured", "http://127.0.0.1:8080", "http://127.0.0.1:9090", false},
+		{"other scheme than configured", "https://git.example.com", "http://git.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := make(gchConfig)
+			if tt.configured != "" {
+				cfg["gch.forge.url"] = []string{tt.configured}
+			}
+			useConfig(t, cfg)
+			if got := sendToken(tt.baseURL); got != tt.want {
+				t.Errorf("sendToken(%q) = %v, want %v", tt.baseURL, got, tt.want)
+			}
+		})
+	}
+}
+
+// stubProvider returns fixed pull requests
+type stubProvider []PullRequest
+
+func (p stubProvider) pullRequests() ([]PullRequest, error) {
+	return p, nil
+}
+
+func TestForgeCacheTTL(t *testing.T) {
+	newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	useConfig(t, gchConfig{"gch.forge.cachettl": {"10m"}})
+
+	pulls := stubProvider{{Number: 1, Title: "Add login", Branch: "feat/login"}}
+	if _, err := refreshPullRequests(pulls, "owner/repo"); err != nil {
+		t.Fatal(err)
+	}
+	cached, fresh := cachedPullRequests("owner/repo")
+	if !fresh || !reflect.DeepEqual(cached, []PullRequest(pulls)) {
+		t.Errorf("cachedPullRequests() = %+v, %v, want the refreshed pull requests", cached, fresh)
+	}
+	if cached, _ := cachedPullRequests("owner/other"); cached != nil {
+		t.Errorf("cachedPullRequests() of another project = %+v", cached)
+	}
+
+	// Age the cache past the TTL
+	path, err := forgeCachePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(forgeCache{Fetched: time.Now().Add(-11 * time.Minute), Project: "owner/repo", PullRequests: pulls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cached, fresh = cachedPullRequests("owner/repo")
+	if fresh || len(cached) != 1 {
+		t.Errorf("cachedPullRequests() = %+v, %v, want the stale pull requests", cached, fresh)
+	}
+}
@@ -0,0 +1,96 @@
+package git
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// githubProvider reads pull requests from the GitHub REST API
+type githubProvider struct {
+	baseURL string
+	// project is "owner/repo"
+	project string
+	token   string
+}
+
+// get fetches a path of the REST API
+func (p githubProvider) get(path string, out any) error {
+	return callAPI(http.MethodGet, p.baseURL+path, map[string]string{
+		"Accept":        "application/vnd.github+json",
+		"Authorization": bearer(p.token),
+	}, nil, out)
+}
+
+// pullRequests returns the open pull requests with their review state. The
+// listing has drafts and pending review requests; approvals come from a
+// single search, since the search API's rate limit is far lower than the
+// rest of the API's. CI state would take a request per pull request and is
+// left out.
+func (p githubProvider) pullRequests() ([]PullRequest, error) {
+	var pulls []struct {
+		Number             int    `json:"number"`
+		Title              string `json:"title"`
+		Draft              bool   `json:"draft"`
+		HTMLURL            string `json:"html_url"`
+		RequestedReviewers []any  `json:"requested_reviewers"`
+		RequestedTeams     []any  `json:"requested_teams"`
+		Head               struct {
+			Ref  string `json:"ref"`
+			Repo *struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"head"`
+	}
+	if err := p.get("/repos/"+p.project+"/pulls?state=open&per_page=100", &pulls); err != nil {
+		return nil, err
+	}
+	if len(pulls) == 0 {
+		return []PullRequest{}, nil
+	}
+
+	// Approvals are an extra; other failed searches leave them out
+	approved := make(map[int]bool)
+	numbers, err := p.search("review:approved")
+	if isRateLimited(err) {
+		return nil, err
+	}
+	for _, number := range numbers {
+		approved[number] = true
+	}
+
+	result := make([]PullRequest, 0, len(pulls))
+	for _, pull := range pulls {
+		pr := PullRequest{Number: pull.Number, Title: pull.Title, URL: pull.HTMLURL}
+		if pull.Head.Repo != nil && pull.Head.Repo.FullName == p.project {
+			pr.Branch = pull.Head.Ref
+		}
+		switch {
+		case pull.Draft:
+			pr.Review = reviewDraft
+		case approved[pull.Number]:
+			pr.Review = reviewApproved
+		case len(pull.RequestedReviewers) > 0 || len(pull.RequestedTeams) > 0:
+			pr.Review = reviewRequired
+		}
+		result = append(result, pr)
+	}
+	return result, nil
+}
+
+// search returns the numbers of the open pull requests matching qualifier
+func (p githubProvider) search(qualifier string) ([]int, error) {
+	query := url.QueryEscape("repo:" + p.project + " is:pr is:open " + qualifier)
+	var response struct {
+		Items []struct {
+			Number int `json:"number"`
+		} `json:"items"`
+	}
+	if err := p.get("/search/issues?per_page=100&q="+query, &response); err != nil {
+		return nil, err
+	}
+	numbers := make([]int, len(response.Items))
+	for i, item := range response.Items {
+		numbers[i] = item.Number
+	}
+	return numbers, nil
+}
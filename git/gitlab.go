@@ -0,0 +1,105 @@
+package git
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// gitlabMaxPages limits how many pages of open merge requests are loaded
+const gitlabMaxPages = 5
+
+// gitlabProvider reads merge requests from the GitLab REST API
+type gitlabProvider struct {
+	baseURL string
+	// project is the full path, e.g. "group/subgroup/repo"
+	project string
+	token   string
+}
+
+// get fetches a path of the project's API
+func (p gitlabProvider) get(path string, out any) error {
+	return callAPI(http.MethodGet, p.baseURL+"/projects/"+url.PathEscape(p.project)+path, map[string]string{
+		"PRIVATE-TOKEN": p.token,
+	}, nil, out)
+}
+
+// pullRequests returns the open merge requests with their approval and
+// pipeline state. The listing and the project's recent pipelines take a
+// fixed number of requests, where the single merge request endpoints would
+// take two per merge request.
+func (p gitlabProvider) pullRequests() ([]PullRequest, error) {
+	type mergeRequest struct {
+		IID             int    `json:"iid"`
+		Title           string `json:"title"`
+		Draft           bool   `json:"draft"`
+		WebURL          string `json:"web_url"`
+		SourceBranch    string `json:"source_branch"`
+		SourceProjectID int    `json:"source_project_id"`
+		TargetProjectID int    `json:"target_project_id"`
+		SHA             string `json:"sha"`
+		// DetailedMergeStatus names the first check that blocks merging
+		DetailedMergeStatus string `json:"detailed_merge_status"`
+	}
+	var mrs []mergeRequest
+	for page := 1; page <= gitlabMaxPages; page++ {
+		var batch []mergeRequest
+		if err := p.get("/merge_requests?state=opened&per_page=100&page="+strconv.Itoa(page), &batch); err != nil {
+			return nil, err
+		}
+		mrs = append(mrs, batch...)
+		if len(batch) < 100 {
+			break
+		}
+	}
+
+	// The newest pipeline of each commit; the CI state is an extra, so a
+	// failed lookup leaves it out
+	pipelines := make(map[string]string)
+	var recent []struct {
+		SHA    string `json:"sha"`
+		Status string `json:"status"`
+	}
+	if err := p.get("/pipelines?per_page=100", &recent); err == nil {
+		for _, pipeline := range recent {
+			if _, ok := pipelines[pipeline.SHA]; !ok {
+				pipelines[pipeline.SHA] = pipeline.Status
+			}
+		}
+	} else if isRateLimited(err) {
+		return nil, err
+	}
+
+	result := make([]PullRequest, 0, len(mrs))
+	for _, mr := range mrs {
+		pr := PullRequest{Number: mr.IID, Title: mr.Title, URL: mr.WebURL}
+		if mr.SourceProjectID == mr.TargetProjectID {
+			pr.Branch = mr.SourceBranch
+		}
+
+		// Approval is only known when it is what blocks the merge, or
+		// nothing does
+		switch {
+		case mr.Draft || mr.DetailedMergeStatus == "draft_status":
+			pr.Review = reviewDraft
+		case mr.DetailedMergeStatus == "not_approved":
+			pr.Review = reviewRequired
+		case mr.DetailedMergeStatus == "requested_changes":
+			pr.Review = reviewChanges
+		case mr.DetailedMergeStatus == "mergeable":
+			pr.Review = reviewApproved
+		}
+
+		switch pipelines[mr.SHA] {
+		case "":
+		case "success", "skipped", "manual":
+			pr.CI = ciPassing
+		case "failed", "canceled":
+			pr.CI = ciFailing
+		default:
+			pr.CI = ciPending
+		}
+		result = append(result, pr)
+	}
+	return result, nil
+}
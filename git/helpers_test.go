@@ -0,0 +1,89 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestRepo creates a repository with one commit of the given files and
+// makes it the working directory
+func newTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "gch")
+	t.Setenv("GIT_AUTHOR_EMAIL", "gch@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "gch")
+	t.Setenv("GIT_COMMITTER_EMAIL", "gch@example.com")
+	t.Chdir(dir)
+
+	for name, content := range files {
+		writeTestFile(t, name, content)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch=main"},
+		{"add", "."},
+		{"commit", "--quiet", "-m", "initial"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	return dir
+}
+
+// runGit runs a git command in the working directory and returns its
+// trimmed output
+func runGit(t *testing.T, args ...string) string {
+	t.Helper()
+	output, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// useConfig replaces the gch settings read from git config with cfg for the
+// rest of the test
+func useConfig(t *testing.T, cfg gchConfig) {
+	t.Helper()
+	configOnce.Do(func() {})
+	userConfigOnce.Do(func() {})
+	loadedConfig, loadedUserConfig = cfg, cfg
+	t.Cleanup(func() {
+		configOnce, userConfigOnce = sync.Once{}, sync.Once{}
+		loadedConfig, loadedUserConfig = nil, nil
+	})
+}
+
+// commitFile commits file with content on the current branch
+func commitFile(t *testing.T, file, content string) {
+	t.Helper()
+	writeTestFile(t, file, content)
+	runGit(t, "add", file)
+	runGit(t, "commit", "--quiet", "-m", "change "+file)
+}
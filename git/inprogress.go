@@ -0,0 +1,136 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// pendingOperation is a rebase, merge, cherry-pick, revert, am or bisect
+// that was started but isn't finished yet
+type pendingOperation struct {
+	name string
+	// continueArgs finish the operation; empty for bisect, which can only
+	// be ended
+	continueArgs []string
+	abortArgs    []string
+	abortLabel   string
+}
+
+// pendingOperations lists the operations in the order they are checked, by
+// the file or directory in the git dir that marks them
+var pendingOperations = []struct {
+	marker string
+	op     pendingOperation
+}{
+	{"rebase-merge", pendingOperation{"rebase", []string{"rebase", "--continue"}, []string{"rebase", "--abort"}, "Abort the rebase"}},
+	{"rebase-apply/applying", pendingOperation{"am", []string{"am", "--continue"}, []string{"am", "--abort"}, "Abort applying the patches"}},
+	{"rebase-apply", pendingOperation{"rebase", []string{"rebase", "--continue"}, []string{"rebase", "--abort"}, "Abort the rebase"}},
+	{"MERGE_HEAD", pendingOperation{"merge", []string{"merge", "--continue"}, []string{"merge", "--abort"}, "Abort the merge"}},
+	{"CHERRY_PICK_HEAD", pendingOperation{"cherry-pick", []string{"cherry-pick", "--continue"}, []string{"cherry-pick", "--abort"}, "Abort the cherry-pick"}},
+	{"REVERT_HEAD", pendingOperation{"revert", []string{"revert", "--continue"}, []string{"revert", "--abort"}, "Abort the revert"}},
+	{"BISECT_LOG", pendingOperation{"bisect", nil, []string{"bisect", "reset"}, "End the bisect (git bisect reset)"}},
+}
+
+// gitPath returns the path of name in the git dir, honoring worktrees
+func gitPath(name string) string {
+	path, err := gitOutput("rev-parse", "--git-path", name)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// pendingOperationInProgress returns the operation in progress, if any
+func pendingOperationInProgress() (pendingOperation, bool) {
+	for _, p := range pendingOperations {
+		path := gitPath(p.marker)
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return p.op, true
+		}
+	}
+	return pendingOperation{}, false
+}
+
+// describe explains the state of the operation in one line
+func (p pendingOperation) describe() string {
+	s := fmt.Sprintf("A %s is in progress", p.name)
+	if p.name == "rebase" {
+		for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+			if head, err := os.ReadFile(gitPath(dir + "/head-name")); err == nil {
+				s = fmt.Sprintf("A rebase of %s is in progress", strings.TrimPrefix(strings.TrimSpace(string(head)), "refs/heads/"))
+				break
+			}
+		}
+	}
+	if conflicts, err := gitOutput("diff", "--name-only", "--diff-filter=U"); err == nil && conflicts != "" {
+		s += fmt.Sprintf(", %d file(s) with conflicts", len(strings.Split(conflicts, "\n")))
+	}
+	return s
+}
+
+// describePending describes the operation in progress for the selector
+// header, or returns an empty string
+func describePending() string {
+	if op, ok := pendingOperationInProgress(); ok {
+		return op.describe()
+	}
+	return ""
+}
+
+// guardPendingOperation stops a checkout while a rebase, merge, cherry-pick,
+// revert, am or bisect is in progress. Interactively it offers to continue or
+// abort the operation first, or to switch anyway.
+func guardPendingOperation() error {
+	op, ok := pendingOperationInProgress()
+	if !ok {
+		return nil
+	}
+
+	if !isInteractive() {
+		hint := "git " + strings.Join(op.abortArgs, " ")
+		if op.continueArgs != nil {
+			hint = "git " + strings.Join(op.continueArgs, " ") + "` or `" + hint
+		}
+		return fmt.Errorf("%s; finish it with `%s` before switching branches", op.describe(), hint)
+	}
+
+	var choices []string
+	var actions [][]string
+	if op.continueArgs != nil {
+		choices = append(choices, fmt.Sprintf("Continue the %s (git %s), then switch", op.name, strings.Join(op.continueArgs, " ")))
+		actions = append(actions, op.continueArgs)
+	}
+	choices = append(choices, op.abortLabel+", then switch", "Switch anyway, leaving the "+op.name+" unfinished", "Cancel")
+	actions = append(actions, op.abortArgs)
+
+	choice, err := promptChoice(op.describe()+". What would you like to do?", choices)
+	if err != nil {
+		return err
+	}
+	switch {
+	case choice < 0 || choice == len(choices)-1:
+		return errors.New("checkout aborted")
+	case choice == len(choices)-2:
+		return nil
+	}
+
+	// The continue commands may open an editor
+	cmd := exec.Command("git", actions[choice]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %w", strings.Join(actions[choice], " "), err)
+	}
+	// A rebase can stop again at the next commit
+	if next, ok := pendingOperationInProgress(); ok {
+		return fmt.Errorf("%s; run gch again once it is finished", next.describe())
+	}
+	return nil
+}
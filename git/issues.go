@@ -0,0 +1,258 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultIssueCacheTTL is how long a cached issue is used without looking
+// it up again when gch.issues.cacheTTL isn't set
+const defaultIssueCacheTTL = time.Hour
+
+// Issue is a ticket in the issue tracker
+type Issue struct {
+	Key    string `json:"key"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+// badge returns the short summary shown next to a branch in the selector
+func (issue Issue) badge() string {
+	title := []rune(issue.Title)
+	if len(title) > 50 {
+		title = append(title[:49], '…')
+	}
+	if issue.Status == "" {
+		return string(title)
+	}
+	return "(" + issue.Status + ") " + string(title)
+}
+
+// issueBatchSize is the most tickets looked up in one request
+const issueBatchSize = 50
+
+// issueTracker looks up tickets in an issue tracker
+type issueTracker interface {
+	// issues looks up keys in one request. Unknown tickets are left out of
+	// the result.
+	issues(keys []string) (map[string]Issue, error)
+}
+
+// cachedIssue is an issue in the on-disk cache. Unknown tickets are cached
+// too, so they aren't looked up again on every start.
+type cachedIssue struct {
+	Issue
+	NotFound bool      `json:"notFound,omitempty"`
+	Fetched  time.Time `json:"fetched"`
+}
+
+// issuesMsg is sent when issues were looked up in the background
+type issuesMsg struct {
+	issues map[string]Issue
+	err    error
+}
+
+// newIssueTracker returns the tracker configured with gch.issues.provider,
+// or nil when none is configured. Like the forge settings, the provider and
+// URL are never read from .gchconfig, so credentials only go to Linear or a
+// Jira the user configured.
+func newIssueTracker() (issueTracker, error) {
+	cfg := userConfig()
+	switch kind := strings.ToLower(cfg.get("gch.issues.provider", "")); kind {
+	case "", "none":
+		return nil, nil
+	case "jira":
+		baseURL := cfg.get("gch.issues.url", "")
+		if baseURL == "" {
+			return nil, fmt.Errorf("gch.issues.url must be set to the Jira URL")
+		}
+		return jiraTracker{
+			baseURL: strings.TrimRight(baseURL, "/"),
+			user:    os.Getenv("JIRA_USER"),
+			token:   os.Getenv("JIRA_TOKEN"),
+		}, nil
+	case "linear":
+		return linearTracker{
+			url:    cfg.get("gch.issues.url", defaultLinearURL),
+			apiKey: os.Getenv("LINEAR_API_KEY"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid gch.issues.provider %q (expected jira, linear or none)", kind)
+	}
+}
+
+// issueKeyPattern returns gch.ticketPattern made case insensitive, so lower
+// case branch names like "feat/proj-12-x" resolve too, or nil when the
+// pattern is invalid
+func issueKeyPattern() *regexp.Regexp {
+	re, err := ticketPattern()
+	if err != nil {
+		return nil
+	}
+	re, err = regexp.Compile("(?i)" + re.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// issueKey returns the upper cased ticket ID re finds in a branch name, or
+// an empty string
+func issueKey(re *regexp.Regexp, name string) string {
+	if re == nil {
+		return ""
+	}
+	return strings.ToUpper(re.FindString(name))
+}
+
+// issueKeys returns the distinct ticket IDs in the names of branches
+func issueKeys(branches []Branch) []string {
+	re := issueKeyPattern()
+	seen := make(map[string]bool)
+	var keys []string
+	for _, b := range branches {
+		if b.Kind != KindBranch {
+			continue
+		}
+		if key := issueKey(re, b.Name); key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// issueCachePath returns the path of the issue cache
+func issueCachePath() (string, error) {
+	dir, err := gchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "issues.json"), nil
+}
+
+// readIssueCache returns the cached issues by key
+func readIssueCache() map[string]cachedIssue {
+	cache := make(map[string]cachedIssue)
+	path, err := issueCachePath()
+	if err != nil {
+		return cache
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// writeIssueCache writes the issue cache
+func writeIssueCache(cache map[string]cachedIssue) {
+	path, err := issueCachePath()
+	if err != nil {
+		return
+	}
+	if data, err := json.Marshal(cache); err == nil {
+		_ = writeStateFile(path, data)
+	}
+}
+
+// cachedIssues returns the cached issues among keys, and the keys that are
+// missing from the cache or older than gch.issues.cacheTTL. Stale issues
+// are still returned so the selector has something to show.
+func cachedIssues(keys []string) (issues map[string]Issue, stale []string) {
+	if tracker, err := newIssueTracker(); err != nil || tracker == nil {
+		return nil, nil
+	}
+
+	ttl := config().getDuration("gch.issues.cacheTTL", defaultIssueCacheTTL)
+	cache := readIssueCache()
+	issues = make(map[string]Issue)
+	for _, key := range keys {
+		cached, ok := cache[key]
+		if ok && !cached.NotFound {
+			issues[key] = cached.Issue
+		}
+		if !ok || time.Since(cached.Fetched) >= ttl {
+			stale = append(stale, key)
+		}
+	}
+	return issues, stale
+}
+
+// lookupIssues asks the tracker for keys in batches of issueBatchSize and
+// updates the cache. Batches that fail are retried next time.
+func lookupIssues(keys []string) (map[string]Issue, error) {
+	tracker, err := newIssueTracker()
+	if err != nil || tracker == nil {
+		return nil, err
+	}
+
+	cache := readIssueCache()
+	issues := make(map[string]Issue)
+	var lastErr error
+	for start := 0; start < len(keys); start += issueBatchSize {
+		batch := keys[start:min(start+issueBatchSize, len(keys))]
+		found, err := tracker.issues(batch)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, key := range batch {
+			if issue, ok := found[key]; ok {
+				issues[key] = issue
+				cache[key] = cachedIssue{Issue: issue, Fetched: time.Now()}
+			} else {
+				cache[key] = cachedIssue{Issue: Issue{Key: key}, NotFound: true, Fetched: time.Now()}
+			}
+		}
+	}
+	writeIssueCache(cache)
+
+	if len(issues) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return issues, nil
+}
+
+// fetchIssues returns a command that looks up keys in the background and
+// reports them as an issuesMsg
+func fetchIssues(keys []string) tea.Cmd {
+	return func() tea.Msg {
+		issues, err := lookupIssues(keys)
+		return issuesMsg{issues: issues, err: err}
+	}
+}
+
+// attachIssues links branches to the issue of the ticket ID in their name
+func attachIssues(branches []Branch, issues map[string]Issue) {
+	re := issueKeyPattern()
+	for i := range branches {
+		if branches[i].Kind != KindBranch {
+			continue
+		}
+		if issue, ok := issues[issueKey(re, branches[i].Name)]; ok {
+			branches[i].Issue = &issue
+		}
+	}
+}
+
+// issueTitle returns the title of ticket from the cache or the tracker, or
+// an empty string when no tracker is configured or the ticket is unknown
+func issueTitle(ticket string, offline bool) (string, error) {
+	key := strings.ToUpper(ticket)
+	issues, stale := cachedIssues([]string{key})
+	if len(stale) > 0 && !offline {
+		fresh, err := lookupIssues(stale)
+		if err != nil {
+			return "", err
+		}
+		issues = fresh
+	}
+	return issues[key].Title, nil
+}
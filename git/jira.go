@@ -0,0 +1,61 @@
+package git
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// jiraTracker reads issues from the Jira REST API
+type jiraTracker struct {
+	baseURL string
+	// user and token authenticate with basic auth (Jira Cloud). A token
+	// without a user is sent as a bearer token (Jira Data Center).
+	user  string
+	token string
+}
+
+// issues returns the summary and status of the Jira issues keys with one
+// `key in (…)` search
+func (t jiraTracker) issues(keys []string) (map[string]Issue, error) {
+	auth := bearer(t.token)
+	if t.user != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(t.user+":"+t.token))
+	}
+
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = strconv.Quote(key)
+	}
+	request := map[string]any{
+		"jql":        "key in (" + strings.Join(quoted, ", ") + ")",
+		"fields":     []string{"summary", "status"},
+		"maxResults": len(keys),
+		// Unknown keys would fail the whole search with the default
+		// "strict"
+		"validateQuery": "warn",
+	}
+	var response struct {
+		Issues []struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Summary string `json:"summary"`
+				Status  struct {
+					Name string `json:"name"`
+				} `json:"status"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	err := callAPI(http.MethodPost, t.baseURL+"/rest/api/2/search",
+		map[string]string{"Authorization": auth, "Accept": "application/json"}, request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make(map[string]Issue, len(response.Issues))
+	for _, issue := range response.Issues {
+		issues[issue.Key] = Issue{Key: issue.Key, Title: issue.Fields.Summary, Status: issue.Fields.Status.Name}
+	}
+	return issues, nil
+}
@@ -0,0 +1,293 @@
+package git
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headState is where HEAD points. Branch is empty for a detached HEAD.
+type headState struct {
+	Head   string `json:"head"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// createdBranch is a branch an operation created, with the commit it was
+// created at
+type createdBranch struct {
+	Name   string `json:"name"`
+	Commit string `json:"commit"`
+}
+
+// journalEntry records one gch operation in .git/gch/journal, with enough of
+// the state before it to undo it
+type journalEntry struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"`
+	Before headState `json:"before"`
+	After  headState `json:"after"`
+	// Stash is the stash commit the operation created
+	Stash   string          `json:"stash,omitempty"`
+	Created []createdBranch `json:"created,omitempty"`
+	// Undoes is the ID of the entry an undo reversed
+	Undoes string `json:"undoes,omitempty"`
+}
+
+// operation tracks the repository state before an operation, so its effects
+// can be journaled when it finishes
+type operation struct {
+	op       string
+	before   headState
+	branches map[string]string
+	stash    string
+}
+
+// trackOperation is called by every entry point that switches branches: it
+// remembers the current branch so `gch -` can return to it and journals op
+// so `gch undo` can reverse it. Call the returned function when the
+// operation is done, usually deferred.
+func trackOperation(op string) func() {
+	previous, _ := getCurrentBranch()
+	journal := beginOperation(op)
+	return func() {
+		journal.finish()
+		recordHistory(previous)
+	}
+}
+
+// beginOperation snapshots the state before the operation op. Call finish
+// when it is done, usually deferred.
+func beginOperation(op string) *operation {
+	return &operation{
+		op:       op,
+		before:   currentHeadState(),
+		branches: localBranchCommits(),
+		stash:    stashTop(),
+	}
+}
+
+// finish journals what the operation changed: the HEAD it moved, a stash it
+// created and the branches it created. Operations that changed nothing
+// aren't journaled.
+func (o *operation) finish() {
+	entry := journalEntry{
+		Op:     o.op,
+		Before: o.before,
+		After:  currentHeadState(),
+	}
+	if stash := stashTop(); stash != "" && stash != o.stash {
+		entry.Stash = stash
+	}
+	for name, commit := range localBranchCommits() {
+		if _, existed := o.branches[name]; !existed {
+			entry.Created = append(entry.Created, createdBranch{Name: name, Commit: commit})
+		}
+	}
+
+	if entry.After == entry.Before && entry.Stash == "" && len(entry.Created) == 0 {
+		return
+	}
+	_ = appendJournal(entry)
+}
+
+// currentHeadState returns the current HEAD commit and branch
+func currentHeadState() headState {
+	return headStateIn("")
+}
+
+// headStateIn returns the HEAD commit and branch of the work tree at dir, or
+// the working directory when dir is empty
+func headStateIn(dir string) headState {
+	head, _ := gitOutputIn(dir, "rev-parse", "--verify", "--quiet", "HEAD")
+	branch, _ := gitOutputIn(dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	return headState{Head: head, Branch: branch}
+}
+
+// localBranchCommits returns the commit of every local branch
+func localBranchCommits() map[string]string {
+	branches := make(map[string]string)
+	output, err := gitOutput("for-each-ref", "--format=%(refname:short)%00%(objectname)", "refs/heads")
+	if err != nil {
+		return branches
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if name, commit, ok := strings.Cut(line, "\x00"); ok {
+			branches[name] = commit
+		}
+	}
+	return branches
+}
+
+// stashTop returns the commit of the newest stash, or an empty string
+func stashTop() string {
+	stash, _ := gitOutput("rev-parse", "--verify", "--quiet", "refs/stash")
+	return stash
+}
+
+// journalPath returns the path of the journal
+func journalPath() (string, error) {
+	dir, err := gchDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal"), nil
+}
+
+// appendJournal adds entry to the end of the journal. Entries are never
+// rewritten; undoing one appends an undo entry.
+func appendJournal(entry journalEntry) error {
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+	entry.Time = time.Now()
+	entry.ID = strconv.FormatInt(entry.Time.UnixNano(), 36)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := openStateFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// readJournal returns all journal entries, oldest first. Lines that can't
+// be parsed are skipped.
+func readJournal() ([]journalEntry, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry journalEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// lastUndoable returns the newest operation that wasn't undone yet
+func lastUndoable(entries []journalEntry) (journalEntry, bool) {
+	undone := make(map[string]bool)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		switch {
+		case entry.Op == "undo":
+			undone[entry.Undoes] = true
+		case !undone[entry.ID]:
+			return entry, true
+		}
+	}
+	return journalEntry{}, false
+}
+
+// Undo reverses the last journaled operation: it switches back to where
+// HEAD was, deletes the branches the operation created if they are still
+// untouched, and re-applies the stash it made. Unless force is set, HEAD
+// must still be where the operation left it.
+func Undo(force bool) error {
+	entries, err := readJournal()
+	if err != nil {
+		return err
+	}
+	entry, ok := lastUndoable(entries)
+	if !ok {
+		return fmt.Errorf("nothing to undo")
+	}
+
+	fmt.Printf("Undoing %s from %s\n", entry.Op, entry.Time.Local().Format("Jan 2 15:04"))
+	if current := currentHeadState(); current != entry.After && !force {
+		return fmt.Errorf("HEAD moved since the %s (now %s); use --force to undo anyway", entry.Op, describeHead(current))
+	}
+
+	// Switch back
+	if current := currentHeadState(); current != entry.Before && entry.Before.Head != "" {
+		target := entry.Before.Branch
+		args := switchArgs(target)
+		if target == "" || !localBranchExists(target) {
+			target = entry.Before.Head
+			args = detachArgs(target)
+		}
+		fmt.Printf("Switching back to %s\n", describeHead(entry.Before))
+		if err := switchHead(func() error { return execGitCommand(args...) }); err != nil {
+			return fmt.Errorf("failed to switch back: %w", err)
+		}
+	}
+
+	// Delete the branches it created, unless there is new work on them
+	branches := localBranchCommits()
+	for _, created := range entry.Created {
+		commit, exists := branches[created.Name]
+		switch {
+		case !exists:
+		case commit != created.Commit:
+			fmt.Printf("Keeping %s: it has changed since it was created\n", created.Name)
+		case created.Name == currentHeadState().Branch:
+			fmt.Printf("Keeping %s: it is checked out\n", created.Name)
+		default:
+			fmt.Printf("Deleting branch %s\n", created.Name)
+			if err := execGitCommand("branch", "-D", "--quiet", created.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Re-apply the stash it made
+	if entry.Stash != "" {
+		if err := popStash("", entry.Stash); err != nil {
+			return err
+		}
+	}
+
+	return appendJournal(journalEntry{Op: "undo", Undoes: entry.ID, Before: entry.After, After: currentHeadState()})
+}
+
+// popStash pops the stash entry with the given commit, restoring the index
+// as well. Git runs in dir, or the working directory when dir is empty.
+func popStash(dir, commit string) error {
+	output, err := gitOutputIn(dir, "stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+	for i, stash := range strings.Split(output, "\n") {
+		if stash == commit {
+			ref := fmt.Sprintf("stash@{%d}", i)
+			fmt.Printf("Re-applying %s\n", ref)
+			return execGitCommandIn(dir, "stash", "pop", "--index", "--quiet", ref)
+		}
+	}
+	fmt.Printf("The stash %s is gone, it was already applied or dropped\n", shortHash(commit))
+	return nil
+}
+
+// describeHead returns a branch name, or the short commit of a detached HEAD
+func describeHead(state headState) string {
+	if state.Branch != "" {
+		return state.Branch
+	}
+	return shortHash(state.Head)
+}
@@ -0,0 +1,115 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+// journalCheckout checks out args as a journaled operation
+func journalCheckout(t *testing.T, op string, args ...string) {
+	t.Helper()
+	operation := beginOperation(op)
+	runGit(t, append([]string{"checkout", "--quiet"}, args...)...)
+	operation.finish()
+}
+
+func TestUndoCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		// work runs on the created branch before the undo
+		work       func(t *testing.T)
+		force      bool
+		wantBranch bool
+	}{
+		{"untouched branch is deleted", func(*testing.T) {}, false, false},
+		{"branch with new commits is kept", func(t *testing.T) {
+			commitFile(t, "new.txt", "new\n")
+		}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n"})
+			journalCheckout(t, "create", "-b", "feature")
+			tt.work(t)
+
+			if err := Undo(tt.force); err != nil {
+				t.Fatal(err)
+			}
+			if branch := currentHeadState().Branch; branch != "main" {
+				t.Errorf("HEAD is on %q, want main", branch)
+			}
+			if exists := localBranchExists("feature"); exists != tt.wantBranch {
+				t.Errorf("feature exists = %v, want %v", exists, tt.wantBranch)
+			}
+		})
+	}
+}
+
+func TestUndoRefusesWhenHeadMoved(t *testing.T) {
+	newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	runGit(t, "branch", "other")
+	journalCheckout(t, "checkout", "other")
+	runGit(t, "checkout", "--quiet", "main")
+	commitFile(t, "b.txt", "b\n")
+	head := currentHeadState()
+
+	err := Undo(false)
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("Undo() = %v, want a refusal mentioning --force", err)
+	}
+	if current := currentHeadState(); current != head {
+		t.Errorf("HEAD = %v, want it left at %v", current, head)
+	}
+
+	if err := Undo(true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndoAppliesItsOwnStash(t *testing.T) {
+	newTestRepo(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+	runGit(t, "branch", "other")
+
+	operation := beginOperation("checkout")
+	writeTestFile(t, "a.txt", "a edited\n")
+	runGit(t, "stash", "push", "--quiet", "-m", "by the checkout")
+	runGit(t, "checkout", "--quiet", "other")
+	operation.finish()
+
+	// A newer stash moves the checkout's stash to stash@{1}
+	writeTestFile(t, "b.txt", "b edited\n")
+	runGit(t, "stash", "push", "--quiet", "-m", "made later")
+
+	if err := Undo(false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, "a.txt"); got != "a edited\n" {
+		t.Errorf("a.txt = %q, want the checkout's stash applied", got)
+	}
+	if got := readTestFile(t, "b.txt"); got != "b\n" {
+		t.Errorf("b.txt = %q, want the later stash left alone", got)
+	}
+	if got := runGit(t, "stash", "list", "--format=%s"); !strings.HasSuffix(got, "made later") || strings.Contains(got, "\n") {
+		t.Errorf("stash list = %q, want only the later stash", got)
+	}
+}
+
+func TestUndoSkipsUndoneEntries(t *testing.T) {
+	newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	runGit(t, "branch", "second")
+	runGit(t, "branch", "third")
+	journalCheckout(t, "checkout", "second")
+	journalCheckout(t, "checkout", "third")
+
+	for _, want := range []string{"second", "main"} {
+		if err := Undo(false); err != nil {
+			t.Fatal(err)
+		}
+		if branch := currentHeadState().Branch; branch != want {
+			t.Fatalf("HEAD is on %q, want %s", branch, want)
+		}
+	}
+	if err := Undo(false); err == nil || err.Error() != "nothing to undo" {
+		t.Errorf("third Undo() = %v, want nothing to undo", err)
+	}
+}
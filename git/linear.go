@@ -0,0 +1,68 @@
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultLinearURL is Linear's API endpoint, used when gch.issues.url isn't set
+const defaultLinearURL = "https://api.linear.app/graphql"
+
+// linearTracker reads issues from the Linear API
+type linearTracker struct {
+	url    string
+	apiKey string
+}
+
+// linearIssue is the part of a Linear issue gch shows
+type linearIssue struct {
+	Identifier string `json:"identifier"`
+	Title      string `json:"title"`
+	State      struct {
+		Name string `json:"name"`
+	} `json:"state"`
+}
+
+// issues returns the title and workflow state of the Linear issues keys
+// with one GraphQL query that looks up each key under an alias
+func (t linearTracker) issues(keys []string) (map[string]Issue, error) {
+	var params, fields []string
+	variables := make(map[string]string, len(keys))
+	for i, key := range keys {
+		n := strconv.Itoa(i)
+		params = append(params, "$k"+n+": String!")
+		fields = append(fields, "i"+n+": issue(id: $k"+n+") { identifier title state { name } }")
+		variables["k"+n] = key
+	}
+	request := map[string]any{
+		"query":     "query(" + strings.Join(params, ", ") + ") { " + strings.Join(fields, " ") + " }",
+		"variables": variables,
+	}
+	var response struct {
+		Data   map[string]*linearIssue `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	err := callAPI(http.MethodPost, t.url, map[string]string{"Authorization": t.apiKey}, request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	// Linear reports unknown issues as errors next to the issues it found
+	for _, e := range response.Errors {
+		if !strings.Contains(strings.ToLower(e.Message), "not found") {
+			return nil, fmt.Errorf("linear: %s", e.Message)
+		}
+	}
+
+	issues := make(map[string]Issue, len(keys))
+	for i, key := range keys {
+		if issue := response.Data["i"+strconv.Itoa(i)]; issue != nil {
+			issues[key] = Issue{Key: issue.Identifier, Title: issue.Title, Status: issue.State.Name}
+		}
+	}
+	return issues, nil
+}
@@ -4,13 +4,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
 )
 
 // branchMatch represents a branch that matches the search pattern
 type branchMatch struct {
-	name    string
-	isLocal bool
-	score   int
+	branch Branch
+	score  int
 }
 
 // calcMatchScore calculates how well a branch matches the pattern
@@ -26,10 +27,11 @@ func calcMatchScore(branch, pattern string) int {
 		return 10000
 	}
 
-	// Check if pattern is a number (like "123")
-	if num, err := strconv.Atoi(pattern); err == nil {
+	// Check if pattern is a number (like "123" or "#123")
+	number := strings.TrimPrefix(pattern, "#")
+	if num, err := strconv.Atoi(number); err == nil {
 		// If branch contains the ticket number
-		if strings.Contains(branch, "#"+pattern) {
+		if strings.Contains(branch, "#"+number) {
 			return 600
 		}
 		// If branch contains the number anywhere
@@ -70,8 +72,6 @@ func calcMatchScore(branch, pattern string) int {
 
 	// Favor common branch names
 	commonBranches := map[string]int{
-		"master":     50,
-		"main":       50,
 		"develop":    40,
 		"dev":        40,
 		"production": 40,
@@ -81,6 +81,15 @@ func calcMatchScore(branch, pattern string) int {
 		"test":       20,
 	}
 
+	// The remote's default branch gets the highest bonus. Fall back to the
+	// usual names when it can't be detected.
+	if defaultBranch := detectedDefaultBranch(); defaultBranch != "" {
+		commonBranches[strings.ToLower(defaultBranch)] = 50
+	} else {
+		commonBranches["main"] = 50
+		commonBranches["master"] = 50
+	}
+
 	// Add score for common branch names
 	for commonBranch, bonus := range commonBranches {
 		if branchLower == commonBranch && strings.Contains(commonBranch, patternLower) {
@@ -91,6 +100,24 @@ func calcMatchScore(branch, pattern string) int {
 	return score
 }
 
+// scoreBranch calculates how well a branch matches the pattern. A "123" or
+// "#123" pattern also matches the number of the branch's pull request, which
+// beats a number that only appears in a branch name.
+func scoreBranch(b Branch, pattern string) int {
+	score := calcMatchScore(b.matchText(), pattern)
+	if b.PR != nil && strings.TrimPrefix(pattern, "#") == strconv.Itoa(b.PR.Number) {
+		score = max(score, 700)
+	}
+	return score
+}
+
+// isPRReference reports whether pattern can match a pull request number in
+// scoreBranch, i.e. is a number with or without a leading "#"
+func isPRReference(pattern string) bool {
+	_, err := strconv.ParseUint(strings.TrimPrefix(pattern, "#"), 10, 0)
+	return err == nil
+}
+
 // containsSubsequence checks if a string contains all characters of a subsequence in order
 // For example, "chestag" is a subsequence of "cheddar/staging"
 func containsSubsequence(s, subseq string) bool {
@@ -114,33 +141,22 @@ func sortMatches(matches []branchMatch) {
 	sort.Slice(matches, func(i, j int) bool {
 		// If scores are equal, prioritize local branches
 		if matches[i].score == matches[j].score {
-			return matches[i].isLocal && !matches[j].isLocal
+			return matches[i].branch.IsLocal && !matches[j].branch.IsLocal
 		}
 		return matches[i].score > matches[j].score
 	})
 }
 
 // createFilteredBranchModel creates a branch model with only matching branches
-func createFilteredBranchModel(matches []branchMatch, debugMode bool) branchModel {
-	// Convert branch matches to Branch objects
+func createFilteredBranchModel(matches []branchMatch, opts Options) (branchModel, error) {
 	branches := make([]Branch, len(matches))
 	for i, match := range matches {
-		branches[i] = Branch{
-			Name:    match.name,
-			IsLocal: match.isLocal,
-			Current: false, // We'll set this later
-		}
+		branches[i] = match.branch
 	}
 
-	// Get current branch to mark it
-	currentBranch, err := getCurrentBranch()
-	if err == nil {
-		for i, branch := range branches {
-			if branch.Name == currentBranch {
-				branches[i].Current = true
-				break
-			}
-		}
+	columns, err := parseColumns(opts.Columns)
+	if err != nil {
+		return branchModel{}, err
 	}
 
 	// Create model with filtered branches
@@ -148,10 +164,18 @@ func createFilteredBranchModel(matches []branchMatch, debugMode bool) branchMode
 		branches:    branches,
 		selected:    0,
 		query:       "",
+		input:       newSearchInput(),
 		width:       80,
 		height:      20,
 		showRemotes: true,
-		debugMode:   debugMode,
+		debugMode:   opts.Debug,
+		opts:        opts,
+		offline:     opts.Offline,
+		spinner:     spinner.New(spinner.WithSpinner(spinner.Dot)),
+		columns:     columns,
+		showColumns: len(columns) > 0,
+		detached:    describeDetached(),
+		pending:     describePending(),
 	}
 
 	// Initial filter to show all matches
@@ -160,5 +184,5 @@ func createFilteredBranchModel(matches []branchMatch, debugMode bool) branchMode
 		model.filteredIdx[i] = i
 	}
 
-	return model
+	return model, nil
 }
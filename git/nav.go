@@ -0,0 +1,214 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Reserved patterns that navigate instead of fuzzy matching
+const (
+	aliasPrevious = "-"
+	aliasDefault  = "^"
+	aliasDefault2 = "@default"
+	aliasUpstream = "@up"
+)
+
+// historySize is the number of previous branches kept in the gch history
+const historySize = 50
+
+// isAlias reports whether pattern is a reserved navigation alias
+func isAlias(pattern string) bool {
+	switch pattern {
+	case aliasPrevious, aliasDefault, aliasDefault2, aliasUpstream:
+		return true
+	}
+	return false
+}
+
+// resolveAlias returns the branch a navigation alias refers to
+func resolveAlias(pattern string) (Branch, error) {
+	switch pattern {
+	case aliasPrevious:
+		name, err := previousBranch()
+		if err != nil {
+			return Branch{}, err
+		}
+		return findBranch(name, "")
+	case aliasDefault, aliasDefault2:
+		name, _, err := getDefaultBranch()
+		if err != nil {
+			return Branch{}, err
+		}
+		return findBranch(name, defaultRemote())
+	case aliasUpstream:
+		return upstreamBranch()
+	}
+	return Branch{}, fmt.Errorf("unknown alias %q", pattern)
+}
+
+// findBranch returns the local branch called name, or the remote-only branch
+// of that name on remote when there is no local one
+func findBranch(name, remote string) (Branch, error) {
+	branches, err := getAllBranches()
+	if err != nil {
+		return Branch{}, err
+	}
+	for _, b := range branches {
+		if b.Name == name && (b.IsLocal || remote == "" || b.Remote == remote) {
+			return b, nil
+		}
+	}
+	return Branch{}, fmt.Errorf("branch '%s' not found", name)
+}
+
+// previousBranch returns the branch checked out before the current one. It
+// asks the reflog first and falls back to gch's own history when the reflog
+// is ambiguous: the previous checkout was a detached HEAD, it points back to
+// the current branch or the branch no longer exists.
+func previousBranch() (string, error) {
+	current, _ := getCurrentBranch()
+
+	if name, err := gitOutput("rev-parse", "--symbolic-full-name", "@{-1}"); err == nil {
+		if name, ok := strings.CutPrefix(name, "refs/heads/"); ok && name != current && localBranchExists(name) {
+			return name, nil
+		}
+	}
+
+	history := readHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if name := history[i]; name != current && localBranchExists(name) {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("no previous branch to switch to")
+}
+
+// upstreamBranch returns the upstream of the current branch as a branch to
+// check out. Upstreams on the local repository ("." remote) are common for
+// stacked branches.
+func upstreamBranch() (Branch, error) {
+	current, err := getCurrentBranch()
+	if err != nil || current == "" {
+		return Branch{}, fmt.Errorf("not on a branch")
+	}
+
+	remote, _ := gitOutput("config", "branch."+current+".remote")
+	merge, _ := gitOutput("config", "branch."+current+".merge")
+	if remote == "" || merge == "" {
+		return Branch{}, fmt.Errorf("branch '%s' has no upstream", current)
+	}
+
+	name := strings.TrimPrefix(merge, "refs/heads/")
+	if remote == "." {
+		return findBranch(name, "")
+	}
+	if name == current {
+		return Branch{}, fmt.Errorf("branch '%s' already tracks %s/%s; use `git pull` to update it", current, remote, name)
+	}
+	return findBranch(name, remote)
+}
+
+// localBranchExists reports whether refs/heads/name exists
+func localBranchExists(name string) bool {
+	return exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+name).Run() == nil
+}
+
+// defaultRemote returns the remote gch resolves the default branch on:
+// checkout.defaultRemote or origin when it exists, otherwise the first
+// configured remote
+func defaultRemote() string {
+	remotes, err := listRemotes()
+	if err != nil || len(remotes) == 0 {
+		return ""
+	}
+	// Prefer the remote git itself guesses from, then origin
+	for _, preferred := range []string{gitConfigValue("checkout.defaultRemote"), "origin"} {
+		for _, remote := range remotes {
+			if remote == preferred {
+				return remote
+			}
+		}
+	}
+	return remotes[0]
+}
+
+var (
+	defaultBranchOnce sync.Once
+	defaultBranchName string
+)
+
+// detectedDefaultBranch returns the default branch name, or an empty string
+// when it can't be determined. The result is cached for the process.
+func detectedDefaultBranch() string {
+	defaultBranchOnce.Do(func() {
+		defaultBranchName, _, _ = getDefaultBranch()
+	})
+	return defaultBranchName
+}
+
+// gchDir returns the directory gch keeps its state in (.git/gch). It only
+// exists once gch wrote something: readers treat it like missing files, and
+// writers go through writeStateFile or openStateFile, which create it.
+func gchDir() (string, error) {
+	gitDir, err := gitOutput("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", fmt.Errorf("failed to find git directory: %w", err)
+	}
+	return filepath.Join(gitDir, "gch"), nil
+}
+
+// writeStateFile writes data to the file at path in gchDir, creating the
+// directory if needed
+func writeStateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// openStateFile opens the file at path in gchDir for appending, creating it
+// and the directory if needed
+func openStateFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+}
+
+// readHistory returns the branches gch switched away from, oldest first
+func readHistory() []string {
+	dir, err := gchDir()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "history"))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
+
+// recordHistory remembers previous in the gch history if the current branch
+// is no longer previous, i.e. a checkout actually happened
+func recordHistory(previous string) {
+	current, err := getCurrentBranch()
+	if err != nil || previous == "" || current == previous {
+		return
+	}
+
+	history := append(readHistory(), previous)
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+
+	dir, err := gchDir()
+	if err != nil {
+		return
+	}
+	_ = writeStateFile(filepath.Join(dir, "history"), []byte(strings.Join(history, "\n")+"\n"))
+}
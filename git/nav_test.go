@@ -0,0 +1,104 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkoutRecorded checks out args and records the branch it left in the gch
+// history, like gch does
+func checkoutRecorded(t *testing.T, args ...string) {
+	t.Helper()
+	previous, _ := getCurrentBranch()
+	runGit(t, append([]string{"checkout", "--quiet"}, args...)...)
+	recordHistory(previous)
+}
+
+func TestPreviousBranch(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T)
+		want    string
+		wantErr bool
+	}{
+		{"from the reflog", func(t *testing.T) {
+			runGit(t, "checkout", "--quiet", "-b", "feature")
+			runGit(t, "checkout", "--quiet", "main")
+		}, "feature", false},
+		{"reflog names a deleted branch", func(t *testing.T) {
+			checkoutRecorded(t, "-b", "feature")
+			checkoutRecorded(t, "-b", "scratch")
+			checkoutRecorded(t, "feature")
+			runGit(t, "branch", "--quiet", "-D", "scratch")
+		}, "main", false},
+		{"reflog names a detached HEAD", func(t *testing.T) {
+			checkoutRecorded(t, "-b", "feature")
+			checkoutRecorded(t, "--detach", "main")
+			checkoutRecorded(t, "feature")
+		}, "main", false},
+		{"no previous branch", func(*testing.T) {}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n"})
+			tt.prepare(t)
+
+			got, err := previousBranch()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("previousBranch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("previousBranch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadingHistoryKeepsGitDirectoryClean(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	if _, err := previousBranch(); err == nil {
+		t.Fatal("expected no previous branch in a new repository")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git", "gch")); !os.IsNotExist(err) {
+		t.Errorf(".git/gch was created by a read: %v", err)
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	runGit(t, "branch", "base")
+	runGit(t, "branch", "other")
+	runGit(t, "branch", "--quiet", "--track", "feature", "base")
+	runGit(t, "checkout", "--quiet", "other")
+	runGit(t, "checkout", "--quiet", "feature")
+
+	tests := []struct {
+		alias   string
+		want    string
+		wantErr bool
+	}{
+		{aliasPrevious, "other", false},
+		{aliasDefault, "main", false},
+		{aliasDefault2, "main", false},
+		{aliasUpstream, "base", false},
+		{"@unknown", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			got, err := resolveAlias(tt.alias)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveAlias(%q) error = %v, wantErr %v", tt.alias, err, tt.wantErr)
+			}
+			if got.Name != tt.want {
+				t.Errorf("resolveAlias(%q) = %q, want %q", tt.alias, got.Name, tt.want)
+			}
+		})
+	}
+
+	// Without an upstream @up has nowhere to go
+	runGit(t, "checkout", "--quiet", "other")
+	if _, err := resolveAlias(aliasUpstream); err == nil {
+		t.Error("resolveAlias(@up) on a branch without upstream succeeded")
+	}
+}
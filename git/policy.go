@@ -0,0 +1,141 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// namingPolicy holds the rules new branch names have to follow. All rules
+// are optional and configured with gch.policy.* keys.
+type namingPolicy struct {
+	allowedPrefixes []string
+	requireTicket   bool
+	ticketPattern   *regexp.Regexp
+	lowercase       bool
+	maxLength       int
+	forbidden       []string
+}
+
+// loadNamingPolicy reads the naming policy from the config
+func loadNamingPolicy() (namingPolicy, error) {
+	cfg := config()
+	policy := namingPolicy{
+		allowedPrefixes: cfg.getAll("gch.policy.allowedPrefix"),
+		requireTicket:   cfg.getBool("gch.policy.requireTicket", false),
+		lowercase:       cfg.getBool("gch.policy.lowercase", false),
+		maxLength:       cfg.getInt("gch.policy.maxLength", 0),
+		forbidden:       cfg.getAll("gch.policy.forbidden"),
+	}
+
+	if policy.requireTicket {
+		re, err := ticketPattern()
+		if err != nil {
+			return namingPolicy{}, err
+		}
+		// Lower case names can only contain lower case ticket IDs
+		if policy.lowercase {
+			re = regexp.MustCompile("(?i)" + re.String())
+		}
+		policy.ticketPattern = re
+	}
+	return policy, nil
+}
+
+// violations returns a description of every rule name breaks
+func (p namingPolicy) violations(name string) []string {
+	var result []string
+
+	if len(p.allowedPrefixes) > 0 && !p.hasAllowedPrefix(name) {
+		result = append(result, "must start with one of: "+strings.Join(p.allowedPrefixes, ", "))
+	}
+	if p.ticketPattern != nil && !p.ticketPattern.MatchString(name) {
+		result = append(result, fmt.Sprintf("must contain a ticket ID matching %s", p.ticketPattern))
+	}
+	if p.lowercase && name != strings.ToLower(name) {
+		result = append(result, "must be lower case")
+	}
+	if p.maxLength > 0 && displayWidth(name) > p.maxLength {
+		result = append(result, fmt.Sprintf("must be at most %d characters (is %d)", p.maxLength, displayWidth(name)))
+	}
+	for _, pattern := range p.forbidden {
+		if ok, _ := path.Match(pattern, name); ok {
+			result = append(result, fmt.Sprintf("matches the forbidden name %q", pattern))
+		}
+	}
+
+	return result
+}
+
+// hasAllowedPrefix reports whether name starts with an allowed prefix
+func (p namingPolicy) hasAllowedPrefix(name string) bool {
+	for _, prefix := range p.allowedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns a name close to name that follows the policy, or an empty
+// string when the violations can't be fixed automatically
+func (p namingPolicy) suggest(name string) string {
+	fixed := name
+	if p.lowercase {
+		fixed = strings.ToLower(fixed)
+	}
+
+	if len(p.allowedPrefixes) > 0 && !p.hasAllowedPrefix(fixed) {
+		// Replace an existing "type/" prefix with the first allowed one
+		if _, rest, ok := strings.Cut(fixed, "/"); ok {
+			fixed = rest
+		}
+		prefix := p.allowedPrefixes[0]
+		if !strings.HasSuffix(prefix, "/") && !strings.HasSuffix(prefix, "-") {
+			prefix += "/"
+		}
+		fixed = prefix + fixed
+	}
+
+	if p.maxLength > 0 {
+		if runes := []rune(fixed); len(runes) > p.maxLength {
+			// Cut at a word boundary after the prefix when possible
+			cut := string(runes[:p.maxLength])
+			if i := strings.LastIndex(cut, "-"); i > strings.LastIndex(cut, "/")+1 {
+				cut = cut[:i]
+			}
+			fixed = strings.TrimRight(cut, "-/._")
+		}
+	}
+
+	if fixed == name || len(p.violations(fixed)) > 0 || checkRefFormat(fixed) != nil {
+		return ""
+	}
+	return fixed
+}
+
+// checkNamingPolicy returns an error listing every policy violation of name
+// together with a suggested fix, or nil when the name is fine
+func checkNamingPolicy(name string) error {
+	policy, err := loadNamingPolicy()
+	if err != nil {
+		return err
+	}
+
+	violations := policy.violations(name)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("branch name '%s' violates the naming policy:", name))
+	for _, v := range violations {
+		sb.WriteString("\n  - " + v)
+	}
+	if suggestion := policy.suggest(name); suggestion != "" {
+		sb.WriteString(fmt.Sprintf("\nsuggestion: %s", suggestion))
+	}
+	return errors.New(sb.String())
+}
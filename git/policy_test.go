@@ -0,0 +1,55 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamingPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		cfg            gchConfig
+		branch         string
+		wantViolations []string
+		wantSuggestion string
+	}{
+		{"no policy", gchConfig{}, "Anything_Goes", nil, ""},
+		{"allowed prefix", gchConfig{"gch.policy.allowedprefix": {"feat/", "fix/"}}, "fix/crash", nil, ""},
+		{"replaces the prefix", gchConfig{"gch.policy.allowedprefix": {"feat/", "fix/"}}, "feature/login",
+			[]string{"must start with one of: feat/, fix/"}, "feat/login"},
+		{"adds a prefix", gchConfig{"gch.policy.allowedprefix": {"feat"}}, "login",
+			[]string{"must start with one of: feat"}, "feat/login"},
+		{"lower case", gchConfig{"gch.policy.lowercase": {"true"}}, "feat/Login",
+			[]string{"must be lower case"}, "feat/login"},
+		{"too long", gchConfig{"gch.policy.maxlength": {"15"}}, "feat/add-login-page",
+			[]string{"must be at most 15 characters (is 19)"}, "feat/add-login"},
+		{"length counts characters", gchConfig{"gch.policy.maxlength": {"10"}}, "feat/ñandú", nil, ""},
+		{"ticket", gchConfig{"gch.policy.requireticket": {"true"}}, "feat/PROJ-12-login", nil, ""},
+		{"lower case ticket", gchConfig{"gch.policy.requireticket": {"true"}, "gch.policy.lowercase": {"true"}}, "feat/proj-12-login", nil, ""},
+		// A missing ticket can't be made up
+		{"missing ticket", gchConfig{"gch.policy.requireticket": {"true"}}, "feat/login",
+			[]string{"must contain a ticket ID matching [A-Z][A-Z0-9]+-[0-9]+"}, ""},
+		{"forbidden", gchConfig{"gch.policy.forbidden": {"tmp*", "test"}}, "tmp-login",
+			[]string{`matches the forbidden name "tmp*"`}, ""},
+		{"several violations", gchConfig{"gch.policy.allowedprefix": {"feat/"}, "gch.policy.lowercase": {"true"}}, "Login",
+			[]string{"must start with one of: feat/", "must be lower case"}, "feat/login"},
+		// Fixing the case alone leaves the ticket violation
+		{"only partly fixable", gchConfig{"gch.policy.requireticket": {"true"}, "gch.policy.lowercase": {"true"}}, "feat/Login",
+			[]string{"must contain a ticket ID matching (?i)[A-Z][A-Z0-9]+-[0-9]+", "must be lower case"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, tt.cfg)
+			policy, err := loadNamingPolicy()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := policy.violations(tt.branch); !reflect.DeepEqual(got, tt.wantViolations) {
+				t.Errorf("violations(%q) = %q, want %q", tt.branch, got, tt.wantViolations)
+			}
+			if got := policy.suggest(tt.branch); got != tt.wantSuggestion {
+				t.Errorf("suggest(%q) = %q, want %q", tt.branch, got, tt.wantSuggestion)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// prRefTemplates are the refs forges publish pull/merge request heads under,
+// tried in order when gch.remote.<name>.prRef isn't set
+var prRefTemplates = []string{
+	"refs/pull/{{number}}/head",           // GitHub, Gitea, Forgejo
+	"refs/merge-requests/{{number}}/head", // GitLab
+}
+
+// prBranchName returns the local branch a pull request is checked out as
+func prBranchName(number int) string {
+	return "pr/" + strconv.Itoa(number)
+}
+
+// CheckoutPR fetches pull request number from remote and checks it out as a
+// local pr/<number> branch that tracks the pull request's head. When the
+// branch already exists it is fast-forwarded to the latest head.
+func CheckoutPR(number int, remote string, opts Options) error {
+	if number <= 0 {
+		return fmt.Errorf("invalid pull request number %d", number)
+	}
+	if remote == "" {
+		remote = config().get("gch.prRemote", defaultRemote())
+	}
+	if remote == "" {
+		return fmt.Errorf("no remote to fetch pull requests from")
+	}
+
+	defer trackOperation("pr")()
+
+	name := prBranchName(number)
+	tracking := "refs/remotes/" + remote + "/" + name
+
+	if opts.Offline {
+		if _, err := gitOutput("rev-parse", "--verify", "--quiet", tracking); err != nil {
+			return fmt.Errorf("pull request #%d from %s was never fetched; run without --offline", number, remote)
+		}
+	} else {
+		ref, err := prHeadRef(remote, number)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Fetching pull request #%d from %s (%s)\n", number, remote, ref)
+		output, err := execGitCommandWithOutput("fetch", "--quiet", remote, "+"+ref+":"+tracking)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s from %s: %s", ref, remote, strings.TrimSpace(output))
+		}
+		if err := setPRUpstream(name, remote, ref); err != nil {
+			return err
+		}
+	}
+
+	if !localBranchExists(name) {
+		if err := execGitCommand("branch", "--no-track", name, tracking); err != nil {
+			return err
+		}
+		return checkoutBranch(Branch{Name: name, IsLocal: true}, opts)
+	}
+
+	return updatePRBranch(name, tracking, opts)
+}
+
+// prHeadRef returns the ref the head of pull request number is published
+// under on remote. gch.remote.<name>.prRef sets the template, with
+// {{number}} standing for the number; otherwise the GitHub and GitLab
+// layouts are looked up with ls-remote.
+func prHeadRef(remote string, number int) (string, error) {
+	n := strconv.Itoa(number)
+	if template := config().get("gch.remote."+remote+".prRef", ""); template != "" {
+		return strings.ReplaceAll(template, "{{number}}", n), nil
+	}
+
+	args := []string{"ls-remote", remote}
+	for _, template := range prRefTemplates {
+		args = append(args, strings.ReplaceAll(template, "{{number}}", n))
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up pull request #%d on %s: %w", number, remote, err)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if _, ref, ok := strings.Cut(line, "\t"); ok {
+			return ref, nil
+		}
+	}
+	return "", fmt.Errorf("pull request #%d not found on %s (set gch.remote.%s.prRef if it uses another ref layout)", number, remote, remote)
+}
+
+// setPRUpstream makes the pull request's head the upstream of branch, so
+// `git pull` updates it as well
+func setPRUpstream(branch, remote, ref string) error {
+	if err := execGitCommand("config", "branch."+branch+".remote", remote); err != nil {
+		return err
+	}
+	return execGitCommand("config", "branch."+branch+".merge", ref)
+}
+
+// updatePRBranch fast-forwards an existing pull request branch to the
+// fetched head and checks it out. Branches that diverged, because the pull
+// request was force-pushed or has local commits, are left alone.
+func updatePRBranch(name, tracking string, opts Options) error {
+	current, _ := getCurrentBranch()
+
+	switch {
+	case isMerged(tracking, name):
+		// Already up to date
+	case !isMerged(name, tracking):
+		fmt.Printf("Warning: %s has diverged from the pull request, not updating it.\n"+
+			"Run `git reset --hard %s` to drop the local state.\n", name, tracking)
+	case current == name:
+		fmt.Printf("Fast-forwarding %s\n", name)
+		return switchHead(func() error {
+			return execGitCommand("merge", "--ff-only", "--quiet", tracking)
+		})
+	default:
+		fmt.Printf("Fast-forwarding %s\n", name)
+		if err := execGitCommand("branch", "--force", "--no-track", name, tracking); err != nil {
+			return err
+		}
+	}
+
+	if current == name {
+		return nil
+	}
+	return checkoutBranch(Branch{Name: name, IsLocal: true}, opts)
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// pushCommit pushes a commit on top of parent that sets file to content to
+// ref on remote, without touching the current branch
+func pushCommit(t *testing.T, remote, ref, parent, file, content string) string {
+	t.Helper()
+	writeTestFile(t, file, content)
+	runGit(t, "add", file)
+	tree := runGit(t, "write-tree")
+	commit := runGit(t, "commit-tree", tree, "-p", parent, "-m", "change "+file)
+	runGit(t, "reset", "--quiet", "--hard")
+	runGit(t, "push", "--quiet", remote, "+"+commit+":"+ref)
+	return commit
+}
+
+func TestCheckoutPR(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		prRef  string
+		ref    string
+	}{
+		{"GitHub layout", "origin", "", "refs/pull/1/head"},
+		{"GitLab layout", "origin", "", "refs/merge-requests/1/head"},
+		{"per-remote template", "review", "refs/changes/{{number}}/head", "refs/changes/1/head"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n"})
+			cfg := make(gchConfig)
+			if tt.prRef != "" {
+				cfg["gch.remote."+tt.remote+".prref"] = []string{tt.prRef}
+			}
+			useConfig(t, cfg)
+			bare := filepath.Join(t.TempDir(), "remote.git")
+			runGit(t, "init", "--quiet", "--bare", bare)
+			runGit(t, "remote", "add", tt.remote, bare)
+			runGit(t, "push", "--quiet", tt.remote, "main")
+
+			head := pushCommit(t, tt.remote, tt.ref, "HEAD", "b.txt", "first\n")
+			if err := CheckoutPR(1, tt.remote, Options{}); err != nil {
+				t.Fatal(err)
+			}
+			if branch := runGit(t, "symbolic-ref", "--short", "HEAD"); branch != "pr/1" {
+				t.Errorf("checked out %s, want pr/1", branch)
+			}
+			if got := runGit(t, "rev-parse", "HEAD"); got != head {
+				t.Errorf("pr/1 is at %s, want %s", got, head)
+			}
+			if got := runGit(t, "config", "branch.pr/1.remote"); got != tt.remote {
+				t.Errorf("branch.pr/1.remote = %s, want %s", got, tt.remote)
+			}
+			if got := runGit(t, "config", "branch.pr/1.merge"); got != tt.ref {
+				t.Errorf("branch.pr/1.merge = %s, want %s", got, tt.ref)
+			}
+
+			// Running it again fast-forwards to the new head
+			head = pushCommit(t, tt.remote, tt.ref, head, "b.txt", "second\n")
+			if err := CheckoutPR(1, tt.remote, Options{}); err != nil {
+				t.Fatal(err)
+			}
+			if got := runGit(t, "rev-parse", "pr/1"); got != head {
+				t.Errorf("pr/1 is at %s after the update, want %s", got, head)
+			}
+			if got := readTestFile(t, "b.txt"); got != "second\n" {
+				t.Errorf("b.txt = %q, want the updated head checked out", got)
+			}
+
+			// Also from another branch
+			runGit(t, "switch", "--quiet", "main")
+			head = pushCommit(t, tt.remote, tt.ref, head, "b.txt", "third\n")
+			if err := CheckoutPR(1, tt.remote, Options{}); err != nil {
+				t.Fatal(err)
+			}
+			if got := runGit(t, "rev-parse", "HEAD"); got != head {
+				t.Errorf("HEAD is at %s after updating from main, want %s", got, head)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+)
+
+// isInteractive reports whether gch can prompt the user, i.e. stdin is a
+// terminal
+func isInteractive() bool {
+	return term.IsTerminal(os.Stdin.Fd())
+}
+
+// promptChoice asks the user to pick one of choices and returns its index,
+// or -1 when the prompt was cancelled
+func promptChoice(title string, choices []string) (int, error) {
+	result, err := tea.NewProgram(&choiceModel{title: title, choices: choices}).Run()
+	if err != nil {
+		return -1, err
+	}
+	m, ok := result.(*choiceModel)
+	if !ok {
+		return -1, errors.New("unexpected result type from prompt")
+	}
+	if m.cancelled {
+		return -1, nil
+	}
+	return m.cursor, nil
+}
+
+// choiceModel is a prompt that lets the user pick one of several choices
+type choiceModel struct {
+	title     string
+	choices   []string
+	cursor    int
+	done      bool
+	cancelled bool
+}
+
+// Init initializes the model
+func (m *choiceModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles messages and updates the model
+func (m *choiceModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.choices)-1 {
+				m.cursor++
+			}
+		case "enter":
+			m.done = true
+			return m, tea.Quit
+		case "q", "esc", "ctrl+c":
+			m.done = true
+			m.cancelled = true
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// View renders the model
+func (m *choiceModel) View() string {
+	if m.done {
+		return ""
+	}
+
+	s := m.title + "\n\n"
+	for i, choice := range m.choices {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		s += fmt.Sprintf("%s %s\n", cursor, choice)
+	}
+
+	s += "\nPress Enter to confirm, q or Esc to abort"
+	return s
+}
+
+// promptText asks the user for a line of text, prefilled with value. It
+// returns an empty string when the prompt was cancelled.
+func promptText(title, value string) (string, error) {
+	input := textinput.New()
+	input.SetValue(value)
+	input.Focus()
+
+	result, err := tea.NewProgram(&textModel{title: title, input: input}).Run()
+	if err != nil {
+		return "", err
+	}
+	m, ok := result.(*textModel)
+	if !ok {
+		return "", errors.New("unexpected result type from prompt")
+	}
+	if m.cancelled {
+		return "", nil
+	}
+	return strings.TrimSpace(m.input.Value()), nil
+}
+
+// textModel is a prompt that asks for a single line of text
+type textModel struct {
+	title     string
+	input     textinput.Model
+	done      bool
+	cancelled bool
+}
+
+// Init initializes the model
+func (m *textModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update handles messages and updates the model
+func (m *textModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "enter":
+			m.done = true
+			return m, tea.Quit
+		case "esc", "ctrl+c":
+			m.done = true
+			m.cancelled = true
+			return m, tea.Quit
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// View renders the model
+func (m *textModel) View() string {
+	if m.done {
+		return ""
+	}
+	return m.title + "\n\n" + m.input.View() + "\n\nPress Enter to confirm, Esc to abort"
+}
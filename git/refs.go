@@ -0,0 +1,148 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTagSort is used when gch.tagSort isn't set
+	defaultTagSort = "version"
+	// defaultCommitLimit is the number of recent commits listed with --refs
+	// when gch.commitLimit isn't set
+	defaultCommitLimit = 100
+)
+
+// tagRefFormat is the for-each-ref format used by getTags. Annotated tags
+// report their tagger and message, lightweight tags those of the commit.
+const tagRefFormat = "%(refname:strip=2)%00%(creatordate:unix)%00" +
+	"%(if)%(taggername)%(then)%(taggername)%(else)%(authorname)%(end)%00%(contents:subject)"
+
+// objectID matches a full SHA-1 or SHA-256 object name
+var objectID = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// getRefs returns the refs the selector and checkout match against: all
+// branches, plus tags with --tags, plus tags and recent commits with --refs
+func getRefs(opts Options) ([]Branch, error) {
+	refs, err := getAllBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Tags || opts.Refs {
+		tags, err := getTags()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, tags...)
+	}
+
+	if opts.Refs {
+		commits, err := getRecentCommits(config().getInt("gch.commitLimit", defaultCommitLimit))
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, commits...)
+	}
+
+	return refs, nil
+}
+
+// getTags returns all tags, sorted by gch.tagSort: "version" puts the
+// highest version first, "date" the most recently created tag
+func getTags() ([]Branch, error) {
+	var sortKey string
+	switch sortBy := config().get("gch.tagSort", defaultTagSort); sortBy {
+	case "version":
+		sortKey = "-v:refname"
+	case "date":
+		sortKey = "-creatordate"
+	default:
+		return nil, fmt.Errorf("invalid gch.tagSort %q (expected version or date)", sortBy)
+	}
+
+	output, err := exec.Command("git", "for-each-ref", "--sort="+sortKey, "--format="+tagRefFormat, "refs/tags").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	var tags []Branch
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		tag := Branch{Name: fields[0], Kind: KindTag, Author: fields[2], Subject: fields[3]}
+		if ts, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			tag.CommitDate = time.Unix(ts, 0)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// getRecentCommits returns the limit most recent commits reachable from any
+// ref. gch's own refs and the stash are left out.
+func getRecentCommits(limit int) ([]Branch, error) {
+	output, err := exec.Command("git", "log", "--exclude=refs/stash", "--exclude=refs/gch/*", "--all",
+		"-n", strconv.Itoa(limit), "--format=%H%x00%h%x00%ct%x00%an%x00%s").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent commits: %w", err)
+	}
+
+	var commits []Branch
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 5 {
+			continue
+		}
+		commit := Branch{Name: fields[1], Hash: fields[0], Kind: KindCommit, Author: fields[3], Subject: fields[4]}
+		if ts, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			commit.CommitDate = time.Unix(ts, 0)
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// checkoutRef checks out a tag or commit. Interactively it asks whether to
+// check it out as a detached HEAD or to create a branch there; otherwise
+// it checks out detached and warns about it.
+func checkoutRef(ref Branch, opts Options) error {
+	if isInteractive() && !opts.Force {
+		choice, err := promptChoice(fmt.Sprintf("%s is a %s, not a branch.", ref.Name, ref.Kind), []string{
+			"Check out " + ref.Name + " as a detached HEAD",
+			"Create a branch at " + ref.Name,
+			"Abort",
+		})
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case 0:
+		case 1:
+			name, err := promptText("Name of the new branch:", ref.Name)
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				return fmt.Errorf("checkout aborted")
+			}
+			opts.From = ref.Ref()
+			return createNewBranch(name, opts)
+		default:
+			return fmt.Errorf("checkout aborted")
+		}
+	}
+
+	if err := checkoutBranch(ref, opts); err != nil {
+		return err
+	}
+	fmt.Printf("Warning: HEAD is now detached at %s. Commits made here belong to no branch;\n"+
+		"create one with `gch -b <name>` to keep them.\n", ref.Name)
+	return nil
+}
@@ -0,0 +1,120 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// remoteHeadsMsg is sent when a background ls-remote lookup finishes
+type remoteHeadsMsg struct {
+	branches []Branch
+	err      error
+}
+
+// listRemotes returns the configured remotes
+func listRemotes() ([]string, error) {
+	output, err := gitOutput("remote")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remotes: %w", err)
+	}
+	if output == "" {
+		return nil, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// splitRemoteRef splits a short remote-tracking name like "origin/feat/x"
+// into the remote and the branch name. Remote names may contain slashes
+// themselves, so the longest of remotes that prefixes ref wins; without
+// one, ref is cut at the first slash.
+func splitRemoteRef(ref string, remotes []string) (remote, name string, ok bool) {
+	for _, r := range remotes {
+		if len(r) > len(remote) && strings.HasPrefix(ref, r+"/") {
+			remote = r
+		}
+	}
+	if remote != "" {
+		return remote, ref[len(remote)+1:], true
+	}
+	return strings.Cut(ref, "/")
+}
+
+// lsRemoteBranches asks every remote which branches it has, without
+// fetching anything. The returned branches are marked as advertised.
+func lsRemoteBranches() ([]Branch, error) {
+	remotes, err := listRemotes()
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []Branch
+	var lastErr error
+	for _, remote := range remotes {
+		cmd := exec.Command("git", "ls-remote", "--heads", remote)
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		output, err := cmd.Output()
+		if err != nil {
+			lastErr = fmt.Errorf("failed to list branches on %s: %w", remote, err)
+			continue
+		}
+
+		for _, line := range strings.Split(string(output), "\n") {
+			_, ref, ok := strings.Cut(line, "\t")
+			if !ok {
+				continue
+			}
+			branches = append(branches, Branch{
+				Name:       strings.TrimPrefix(ref, "refs/heads/"),
+				Remote:     remote,
+				Advertised: true,
+			})
+		}
+	}
+
+	// Only fail when no remote could be reached
+	if len(branches) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return branches, nil
+}
+
+// lsRemoteMatches scores the branches advertised by all remotes against
+// pattern and returns the ones that match
+func lsRemoteMatches(pattern string) ([]branchMatch, error) {
+	branches, err := lsRemoteBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []branchMatch
+	for _, b := range branches {
+		if score := calcMatchScore(b.Name, pattern); score > 0 {
+			matches = append(matches, branchMatch{branch: b, score: score})
+		}
+	}
+	return matches, nil
+}
+
+// lookupRemoteHeads returns a command that lists the branches advertised by
+// all remotes in the background
+func lookupRemoteHeads() tea.Cmd {
+	return func() tea.Msg {
+		branches, err := lsRemoteBranches()
+		return remoteHeadsMsg{branches: branches, err: err}
+	}
+}
+
+// fetchBranch fetches just the given branch from its remote into its
+// remote-tracking ref
+func fetchBranch(b Branch) error {
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/%s", b.Name, b.RemoteRef())
+	output, err := execGitCommandWithOutput("fetch", "--quiet", b.Remote, refspec)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %s", b.RemoteRef(), strings.TrimSpace(output))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Modes of a post-checkout rule (gch.rule.<name>.mode)
+const (
+	// ruleSuggest prints the command without running it
+	ruleSuggest = "suggest"
+	// ruleAsk asks every time whether to run the command
+	ruleAsk = "ask"
+	// ruleRun runs the command without asking once it was approved
+	ruleRun = "run"
+)
+
+// checkoutRule runs or suggests a command when files matching its paths
+// differ between the old and the new HEAD
+type checkoutRule struct {
+	name    string
+	paths   []string
+	command string
+	mode    string
+}
+
+// loadCheckoutRules reads the gch.rule.<name>.* settings. Rules without
+// paths or a command are ignored.
+func loadCheckoutRules() ([]checkoutRule, error) {
+	cfg := config()
+	names := make(map[string]bool)
+	for key := range cfg {
+		if rest, ok := strings.CutPrefix(key, "gch.rule."); ok {
+			if i := strings.LastIndex(rest, "."); i > 0 {
+				names[rest[:i]] = true
+			}
+		}
+	}
+
+	var rules []checkoutRule
+	for name := range names {
+		rule := checkoutRule{
+			name:    name,
+			paths:   cfg.getAll("gch.rule." + name + ".paths"),
+			command: cfg.get("gch.rule."+name+".run", ""),
+			mode:    cfg.get("gch.rule."+name+".mode", ruleSuggest),
+		}
+		if len(rule.paths) == 0 || rule.command == "" {
+			continue
+		}
+		switch rule.mode {
+		case ruleSuggest, ruleAsk, ruleRun:
+		default:
+			return nil, fmt.Errorf("invalid gch.rule.%s.mode %q (expected run, ask or suggest)", name, rule.mode)
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].name < rules[j].name })
+	return rules, nil
+}
+
+// matches returns the first changed file matching one of the rule's paths.
+// Patterns without a slash match the file name in any directory, patterns
+// ending in a slash match everything below that directory.
+func (r checkoutRule) matches(changed []string) string {
+	for _, file := range changed {
+		for _, pattern := range r.paths {
+			switch {
+			case strings.HasSuffix(pattern, "/"):
+				if strings.HasPrefix(file, pattern) {
+					return file
+				}
+			case !strings.Contains(pattern, "/"):
+				if ok, _ := path.Match(pattern, path.Base(file)); ok {
+					return file
+				}
+			default:
+				if ok, _ := path.Match(pattern, file); ok {
+					return file
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// approvalKey identifies a rule's command, so changing the command in the
+// shared config requires a new approval
+func (r checkoutRule) approvalKey() string {
+	sum := sha256.Sum256([]byte(r.name + "\x00" + r.command))
+	return hex.EncodeToString(sum[:])
+}
+
+// approvedRules returns the keys of the rules the user allowed to run
+// without asking, kept in .git/gch/approved-rules
+func approvedRules() map[string]bool {
+	approved := make(map[string]bool)
+	dir, err := gchDir()
+	if err != nil {
+		return approved
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "approved-rules"))
+	if err != nil {
+		return approved
+	}
+	for _, key := range strings.Fields(string(data)) {
+		approved[key] = true
+	}
+	return approved
+}
+
+// approveRule remembers that the rule may run without asking
+func approveRule(r checkoutRule) error {
+	dir, err := gchDir()
+	if err != nil {
+		return err
+	}
+	f, err := openStateFile(filepath.Join(dir, "approved-rules"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, r.approvalKey())
+	return err
+}
+
+// runCheckoutRules prints which rules apply to the files that differ
+// between before and the new HEAD, and runs the approved ones. A rule's
+// command failing doesn't fail the checkout, which already happened.
+func runCheckoutRules(before string) error {
+	after, err := gitOutput("rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil || before == "" || before == after {
+		return nil
+	}
+	rules, err := loadCheckoutRules()
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	output, err := gitOutput("diff", "--name-only", "--no-renames", before, after)
+	if err != nil || output == "" {
+		return nil
+	}
+	changed := strings.Split(output, "\n")
+
+	type match struct {
+		rule checkoutRule
+		file string
+	}
+	var matched []match
+	for _, rule := range rules {
+		if file := rule.matches(changed); file != "" {
+			matched = append(matched, match{rule: rule, file: file})
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	fmt.Println("Post-checkout:")
+	for _, m := range matched {
+		fmt.Printf("  %s: %s changed -> %s\n", m.rule.name, m.file, m.rule.command)
+	}
+
+	approved := approvedRules()
+	for _, m := range matched {
+		if !shouldRunRule(m.rule, approved) {
+			fmt.Printf("Run `%s` to update (%s).\n", m.rule.command, m.rule.name)
+			continue
+		}
+		if err := runRuleCommand(m.rule); err != nil {
+			fmt.Printf("Warning: %s failed: %v\n", m.rule.name, err)
+		}
+	}
+	return nil
+}
+
+// shouldRunRule decides whether to run a matched rule now. Rules in run
+// mode only run unattended after they were approved once, since commands
+// from a committed .gchconfig would otherwise run on checkout unseen.
+func shouldRunRule(r checkoutRule, approved map[string]bool) bool {
+	switch {
+	case r.mode == ruleSuggest:
+		return false
+	case r.mode == ruleRun && approved[r.approvalKey()]:
+		return true
+	case !isInteractive():
+		return false
+	}
+
+	choices := []string{"Run it", "Skip"}
+	if r.mode == ruleRun {
+		choices = []string{"Run it", "Skip", "Always run it without asking"}
+	}
+	choice, err := promptChoice(fmt.Sprintf("Run `%s` for %s?", r.command, r.name), choices)
+	if err != nil {
+		return false
+	}
+	if choice == 2 {
+		if err := approveRule(r); err != nil {
+			fmt.Printf("Warning: failed to remember the approval: %v\n", err)
+		}
+	}
+	return choice == 0 || choice == 2
+}
+
+// runRuleCommand runs the rule's command with a shell at the top of the
+// work tree, streaming its output
+func runRuleCommand(r checkoutRule) error {
+	fmt.Printf("Running %s: %s\n", r.name, r.command)
+	cmd := exec.Command("sh", "-c", r.command)
+	if top, err := gitOutput("rev-parse", "--show-toplevel"); err == nil {
+		cmd.Dir = top
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
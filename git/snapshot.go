@@ -0,0 +1,270 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshotRefs is the ref namespace snapshots are kept in
+const snapshotRefs = "refs/gch/snapshots/"
+
+// Defaults of the snapshot retention limits (gch.snapshots.keep and
+// gch.snapshots.maxAge)
+const (
+	defaultSnapshotKeep   = 20
+	defaultSnapshotMaxAge = 30 * 24 * time.Hour
+)
+
+// Snapshot is a saved copy of local changes, taken before gch threw them
+// away
+type Snapshot struct {
+	// ID names the snapshot, refs/gch/snapshots/<ID>
+	ID      string
+	Commit  string
+	Time    time.Time
+	Message string
+}
+
+// takeSnapshot saves the local changes of the work tree at dir, or the
+// working directory when dir is empty, in a stash commit under
+// refs/gch/snapshots before an operation discards them. Untracked files are
+// included when gch.snapshots.untracked is set. It does nothing for a clean
+// work tree.
+func takeSnapshot(dir, reason string) error {
+	status, err := gitOutputIn(dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return err
+	}
+	untracked := config().getBool("gch.snapshots.untracked", false)
+	var untrackedList []string
+	if untracked {
+		untrackedList = untrackedFiles(dir)
+	}
+	if status == "" && len(untrackedList) == 0 {
+		return nil
+	}
+
+	message := "gch snapshot before " + reason
+	commit, err := gitOutputIn(dir, "stash", "create", message)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	if len(untrackedList) > 0 {
+		if commit, err = addUntrackedParent(dir, commit, message, untrackedList); err != nil {
+			return fmt.Errorf("failed to snapshot untracked files: %w", err)
+		}
+	}
+	if commit == "" {
+		return nil
+	}
+
+	id := time.Now().UTC().Format("20060102-150405.000")
+	if err := execGitCommandIn(dir, "update-ref", "-m", message, snapshotRefs+id, commit); err != nil {
+		return fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	fmt.Printf("Saved a snapshot of your changes; restore it with `gch recover %s`\n", id)
+
+	if err := pruneSnapshots(dir); err != nil {
+		fmt.Printf("Warning: failed to prune old snapshots: %v\n", err)
+	}
+	return nil
+}
+
+// addUntrackedParent adds the untracked files to the stash commit as its
+// third parent, the layout `git stash -u` uses. Without a stash commit, i.e.
+// when only untracked files changed, one is made for HEAD.
+func addUntrackedParent(dir, stash, message string, files []string) (string, error) {
+	gitDir, err := gitOutputIn(dir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	top, err := gitOutputIn(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	// Build the tree of untracked files in a temporary index
+	index := filepath.Join(gitDir, "gch", "snapshot-index")
+	if err := os.MkdirAll(filepath.Dir(index), 0o755); err != nil {
+		return "", err
+	}
+	defer os.Remove(index)
+	add := exec.Command("git", append([]string{"update-index", "--add", "--"}, files...)...)
+	add.Dir = top
+	add.Env = append(os.Environ(), "GIT_INDEX_FILE="+index)
+	if output, err := add.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	writeTree := exec.Command("git", "write-tree")
+	writeTree.Dir = top
+	writeTree.Env = append(os.Environ(), "GIT_INDEX_FILE="+index)
+	output, err := writeTree.Output()
+	if err != nil {
+		return "", err
+	}
+	untracked, err := gitOutputIn(dir, "commit-tree", strings.TrimSpace(string(output)), "-m", "untracked files of "+message)
+	if err != nil {
+		return "", err
+	}
+
+	if stash == "" {
+		head, err := gitOutputIn(dir, "rev-parse", "HEAD")
+		if err != nil {
+			return "", err
+		}
+		index, err := gitOutputIn(dir, "commit-tree", head+"^{tree}", "-p", head, "-m", "index of "+message)
+		if err != nil {
+			return "", err
+		}
+		return gitOutputIn(dir, "commit-tree", head+"^{tree}", "-p", head, "-p", index, "-p", untracked, "-m", message)
+	}
+	return gitOutputIn(dir, "commit-tree", stash+"^{tree}", "-p", stash+"^1", "-p", stash+"^2", "-p", untracked, "-m", message)
+}
+
+// listSnapshots returns the snapshots of the repository at dir, or the
+// working directory when dir is empty, newest first
+func listSnapshots(dir string) ([]Snapshot, error) {
+	output, err := gitOutputIn(dir, "for-each-ref", "--sort=-refname",
+		"--format=%(refname)%00%(objectname)%00%(creatordate:unix)%00%(subject)", snapshotRefs)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		unix, _ := strconv.ParseInt(fields[2], 10, 64)
+		snapshots = append(snapshots, Snapshot{
+			ID:      strings.TrimPrefix(fields[0], snapshotRefs),
+			Commit:  fields[1],
+			Time:    time.Unix(unix, 0),
+			Message: fields[3],
+		})
+	}
+	return snapshots, nil
+}
+
+// pruneSnapshots deletes snapshots beyond gch.snapshots.keep and those older
+// than gch.snapshots.maxAge from the repository at dir
+func pruneSnapshots(dir string) error {
+	cfg := config()
+	keep := cfg.getInt("gch.snapshots.keep", defaultSnapshotKeep)
+	maxAge := cfg.getDuration("gch.snapshots.maxAge", defaultSnapshotMaxAge)
+
+	snapshots, err := listSnapshots(dir)
+	if err != nil {
+		return err
+	}
+	for i, s := range snapshots {
+		if i >= keep || time.Since(s.Time) > maxAge {
+			if err := execGitCommandIn(dir, "update-ref", "-d", snapshotRefs+s.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findSnapshot returns the snapshot with the given ID, which may be
+// shortened to a unique prefix
+func findSnapshot(snapshots []Snapshot, id string) (Snapshot, error) {
+	var found []Snapshot
+	for _, s := range snapshots {
+		if s.ID == id {
+			return s, nil
+		}
+		if strings.HasPrefix(s.ID, id) {
+			found = append(found, s)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Snapshot{}, fmt.Errorf("no snapshot '%s'; run `gch recover --list` to see them", id)
+	case 1:
+		return found[0], nil
+	default:
+		return Snapshot{}, fmt.Errorf("'%s' matches %d snapshots", id, len(found))
+	}
+}
+
+// describeSnapshot returns a line describing s, with the files it changed
+func describeSnapshot(s Snapshot) string {
+	files, _ := gitOutput("diff", "--name-only", s.Commit+"^1", s.Commit)
+	if untracked, err := gitOutput("ls-tree", "-r", "--name-only", s.Commit+"^3"); err == nil && untracked != "" {
+		files = strings.TrimSpace(files + "\n" + untracked)
+	}
+	count := 0
+	if files != "" {
+		count = len(strings.Split(files, "\n"))
+	}
+	// git stash create prefixes the message with "On <branch>: "
+	_, reason, _ := strings.Cut(s.Message, "gch snapshot ")
+	return fmt.Sprintf("%s  %s ago  %s (%d files)", s.ID, formatAge(time.Since(s.Time)), reason, count)
+}
+
+// ListSnapshots prints the snapshots, newest first
+func ListSnapshots() error {
+	snapshots, err := listSnapshots("")
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots")
+		return nil
+	}
+	for _, s := range snapshots {
+		fmt.Println(describeSnapshot(s))
+	}
+	return nil
+}
+
+// Recover restores the snapshot with the given ID on top of the work tree.
+// Without an ID the user picks one, or the snapshots are listed when there
+// is no terminal.
+func Recover(id string) error {
+	snapshots, err := listSnapshots("")
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return errors.New("no snapshots to recover")
+	}
+
+	var snapshot Snapshot
+	switch {
+	case id != "":
+		if snapshot, err = findSnapshot(snapshots, id); err != nil {
+			return err
+		}
+	case !isInteractive():
+		return ListSnapshots()
+	default:
+		choices := make([]string, len(snapshots))
+		for i, s := range snapshots {
+			choices[i] = describeSnapshot(s)
+		}
+		choice, err := promptChoice("Which snapshot do you want to restore?", choices)
+		if err != nil {
+			return err
+		}
+		if choice < 0 {
+			return errors.New("recover aborted")
+		}
+		snapshot = snapshots[choice]
+	}
+
+	fmt.Printf("Restoring snapshot %s\n", snapshot.ID)
+	if err := execGitCommand("stash", "apply", "--quiet", "--index", snapshot.Commit); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", snapshot.ID, err)
+	}
+	fmt.Println("Your changes were restored")
+	return nil
+}
@@ -0,0 +1,66 @@
+package git
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSnapshotRecover(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           gchConfig
+		wantUntracked bool
+	}{
+		{"tracked changes only", gchConfig{}, false},
+		{"with untracked files", gchConfig{"gch.snapshots.untracked": {"true"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+			useConfig(t, tt.cfg)
+			// c.txt is tracked on other, so the forced checkout overwrites
+			// the untracked c.txt and the way back removes it
+			runGit(t, "checkout", "--quiet", "-b", "other")
+			commitFile(t, "c.txt", "c on other\n")
+			runGit(t, "checkout", "--quiet", "main")
+
+			writeTestFile(t, "a.txt", "a staged\n")
+			runGit(t, "add", "a.txt")
+			writeTestFile(t, "b.txt", "b unstaged\n")
+			writeTestFile(t, "c.txt", "c untracked\n")
+
+			if err := takeSnapshot("", "discarding changes"); err != nil {
+				t.Fatal(err)
+			}
+			runGit(t, "checkout", "--quiet", "--force", "other")
+			runGit(t, "checkout", "--quiet", "main")
+			if _, err := os.Stat("c.txt"); !os.IsNotExist(err) {
+				t.Fatalf("c.txt survived the forced checkout: %v", err)
+			}
+
+			snapshots, err := listSnapshots("")
+			if err != nil || len(snapshots) != 1 {
+				t.Fatalf("listSnapshots() = %v, %v; want one snapshot", snapshots, err)
+			}
+			if err := Recover(snapshots[0].ID); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := runGit(t, "diff", "--cached", "--name-only"); got != "a.txt" {
+				t.Errorf("staged = %q, want a.txt", got)
+			}
+			if got := runGit(t, "diff", "--name-only"); got != "b.txt" {
+				t.Errorf("unstaged = %q, want b.txt", got)
+			}
+			_, err = os.Stat("c.txt")
+			if restored := err == nil; restored != tt.wantUntracked {
+				t.Fatalf("c.txt restored = %v, want %v", restored, tt.wantUntracked)
+			}
+			if tt.wantUntracked {
+				if got := readTestFile(t, "c.txt"); got != "c untracked\n" {
+					t.Errorf("c.txt = %q, want the untracked content", got)
+				}
+			}
+		})
+	}
+}
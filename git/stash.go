@@ -0,0 +1,192 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// errInterrupted is returned when the user interrupts a stash-and-switch
+var errInterrupted = errors.New("interrupted")
+
+// stashAndSwitch stashes the local changes and runs switch as one step: if
+// the switch fails or is interrupted with Ctrl+C, HEAD is put back where it
+// was and the stash is re-applied, so changes are never left stashed behind
+// the user's back. The stash can remove the directory gch runs in, so
+// switchFn and everything after the stash run git at top, the top of the
+// work tree.
+func stashAndSwitch(opts stashOptions, switchFn func(top string) error) error {
+	before := currentHeadState()
+	top, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return fmt.Errorf("failed to find the top of the work tree: %w", err)
+	}
+
+	// git receives Ctrl+C as well and stops on its own; gch stays alive to
+	// roll back
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+	interrupted := func() bool {
+		select {
+		case <-interrupts:
+			return true
+		default:
+			return false
+		}
+	}
+
+	stash, err := pushStash(top, opts)
+	if err != nil {
+		err = fmt.Errorf("failed to stash changes: %w", err)
+		if stash != "" {
+			return rollbackSwitch(top, before, stash, opts, err)
+		}
+		return err
+	}
+	if interrupted() {
+		return rollbackSwitch(top, before, stash, opts, errInterrupted)
+	}
+
+	err = switchFn(top)
+	if interrupted() {
+		err = errInterrupted
+	}
+	if err != nil {
+		return rollbackSwitch(top, before, stash, opts, err)
+	}
+	return nil
+}
+
+// pushStash stashes the changes opts selects in the work tree at top and
+// returns the new stash commit, or an empty string when nothing was stashed.
+// Whether a stash was made is read from the stash list, so a stash is
+// reported even when git stash push fails afterwards.
+func pushStash(top string, opts stashOptions) (string, error) {
+	before, err := stashCount(top)
+	if err != nil {
+		return "", err
+	}
+	pushErr := execGitCommandIn(top, stashArgs(opts)...)
+	after, err := stashCount(top)
+	if err != nil {
+		return "", err
+	}
+
+	var stash string
+	if after > before {
+		if stash, err = gitOutputIn(top, "rev-parse", "--verify", "--quiet", "refs/stash"); err != nil {
+			return "", fmt.Errorf("a stash was made but can't be found: %w", err)
+		}
+	}
+	return stash, pushErr
+}
+
+// stashCount returns the number of stash entries
+func stashCount(top string) (int, error) {
+	output, err := gitOutputIn(top, "stash", "list", "--format=%H")
+	if err != nil {
+		return 0, err
+	}
+	if output == "" {
+		return 0, nil
+	}
+	return len(strings.Split(output, "\n")), nil
+}
+
+// rollbackSwitch undoes a failed stash-and-switch in the work tree at top:
+// it returns HEAD to before, resets the stashed files a partial checkout
+// left behind and re-applies the stash. Changes that weren't stashed are
+// never forced away. The returned error describes the failure and the
+// outcome.
+func rollbackSwitch(top string, before headState, stash string, opts stashOptions, cause error) error {
+	if errors.Is(cause, errInterrupted) {
+		fmt.Println("Interrupted, rolling back")
+	} else {
+		fmt.Println("Checkout failed, rolling back")
+	}
+
+	// Whatever is forced away below was written by the checkout, but keep a
+	// copy in case it wasn't
+	if stash != "" && before.Head != "" {
+		if err := takeSnapshot(top, "rolling back a checkout"); err != nil {
+			return fmt.Errorf("checkout failed (%v) and saving a snapshot failed too: %w; your changes are in the stash %s",
+				cause, err, shortHash(stash))
+		}
+	}
+
+	if current := headStateIn(top); current != before && before.Head != "" {
+		args := switchArgs(before.Branch)
+		if before.Branch == "" {
+			args = detachArgs(before.Head)
+		}
+		args = append(args, "--quiet")
+		if stash != "" {
+			args = append(args, "--force")
+		}
+		if err := execGitCommandIn(top, args...); err != nil {
+			return fmt.Errorf("checkout failed (%v) and switching back to %s failed too: %w; your changes are in the stash %s",
+				cause, describeHead(before), err, shortHash(stash))
+		}
+		fmt.Printf("Switched back to %s\n", describeHead(before))
+	} else if stash != "" && before.Head != "" {
+		// Only the stashed files can differ from HEAD because of a checkout
+		// that stopped halfway; everything else is the user's
+		if err := resetStashedFiles(top, opts); err != nil {
+			return fmt.Errorf("checkout failed (%v) and resetting the work tree failed too: %w; your changes are in the stash %s",
+				cause, err, shortHash(stash))
+		}
+	}
+
+	if stash != "" {
+		if err := popStash(top, stash); err != nil {
+			return fmt.Errorf("checkout failed (%v) and re-applying the stash failed: %w; your changes are in the stash %s",
+				cause, err, shortHash(stash))
+		}
+		fmt.Println("Your changes were restored")
+	}
+	if errors.Is(cause, errInterrupted) {
+		return errors.New("checkout interrupted, nothing changed")
+	}
+	return fmt.Errorf("checkout failed, nothing changed: %w", cause)
+}
+
+// resetStashedFiles resets the tracked files a stash made with opts covers to
+// HEAD, or all tracked files when the stash wasn't limited to paths, in the
+// work tree at top
+func resetStashedFiles(top string, opts stashOptions) error {
+	if len(opts.paths) == 0 {
+		return execGitCommandIn(top, "reset", "--quiet", "--hard")
+	}
+
+	// Untracked paths aren't in HEAD and can't be checked out from it
+	output, err := gitOutputIn(top, "ls-tree", "-r", "-z", "--name-only", "--full-tree", "HEAD")
+	if err != nil {
+		return err
+	}
+	inHead := make(map[string]bool)
+	for _, path := range splitNul(output) {
+		inHead[path] = true
+	}
+	var paths []string
+	for _, path := range opts.paths {
+		if inHead[path] {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return execGitCommandIn(top, append([]string{"checkout", "--quiet", "HEAD", "--"}, topPaths(paths)...)...)
+}
+
+// shortHash abbreviates a commit hash for messages
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,97 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRollbackKeepsUnstashedChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+	}{
+		// Only a.txt is stashed; b.txt stays in the work tree
+		{"path-limited stash", []string{"a.txt"}},
+		// c.txt has no changes, so nothing is stashed at all
+		{"empty stash", []string{"c.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n", "b.txt": "b\n", "c.txt": "c\n"})
+			writeTestFile(t, "a.txt", "a edited\n")
+			writeTestFile(t, "b.txt", "b edited\n")
+
+			err := stashAndSwitch(stashOptions{paths: tt.paths}, func(string) error {
+				return errors.New("checkout failed")
+			})
+			if err == nil {
+				t.Fatal("expected the failed switch to be reported")
+			}
+
+			if got := readTestFile(t, "a.txt"); got != "a edited\n" {
+				t.Errorf("a.txt = %q, want the edit restored", got)
+			}
+			if got := readTestFile(t, "b.txt"); got != "b edited\n" {
+				t.Errorf("b.txt = %q, want the edit kept", got)
+			}
+			if stash := stashTop(); stash != "" {
+				t.Errorf("stash %s left behind", stash)
+			}
+		})
+	}
+}
+
+func TestRollbackAfterStashRemovesWorkingDirectory(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	writeTestFile(t, "sub/blocker.txt", "blocker\n")
+	// The stash removes sub, the directory gch runs in
+	t.Chdir(filepath.Join(dir, "sub"))
+
+	opts := stashOptions{untracked: true, paths: []string{"sub/blocker.txt"}}
+	err := stashAndSwitch(opts, func(string) error {
+		return errors.New("checkout failed")
+	})
+	if err == nil {
+		t.Fatal("expected the failed switch to be reported")
+	}
+	if strings.Contains(err.Error(), "in the stash") {
+		t.Errorf("error = %q, want the stash re-applied", err)
+	}
+
+	if got := readTestFile(t, filepath.Join(dir, "sub", "blocker.txt")); got != "blocker\n" {
+		t.Errorf("sub/blocker.txt = %q, want it restored", got)
+	}
+	if stash, _ := gitOutputIn(dir, "rev-parse", "--verify", "--quiet", "refs/stash"); stash != "" {
+		t.Errorf("stash %s left behind", stash)
+	}
+	top, _ := filepath.EvalSymlinks(dir)
+	if wd, err := os.Getwd(); err == nil && wd == top {
+		t.Errorf("the working directory was changed to the top of the work tree")
+	}
+}
+
+func TestConflictStashFromSubdirectory(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{"sub/tracked.txt": "tracked\n", "top.txt": "top\n"})
+	writeTestFile(t, "sub/tracked.txt", "tracked edited\n")
+	writeTestFile(t, "top.txt", "top edited\n")
+	writeTestFile(t, "sub/blocker ä.txt", "blocker\n")
+	writeTestFile(t, "sub/other.txt", "other\n")
+	t.Chdir(filepath.Join(dir, "sub"))
+
+	conflict := checkoutConflict{untracked: true, files: []string{"sub/blocker ä.txt"}}
+	if err := stashChanges(conflictStash(conflict)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"tracked.txt": "tracked\n", "../top.txt": "top\n", "other.txt": "other\n"} {
+		if got := readTestFile(t, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat("blocker ä.txt"); !os.IsNotExist(err) {
+		t.Errorf("blocker ä.txt is still in the way")
+	}
+}
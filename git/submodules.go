@@ -0,0 +1,176 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Submodule update policies (gch.submodules)
+const (
+	submodulesAlways = "always"
+	submodulesAsk    = "ask"
+	submodulesNever  = "never"
+)
+
+// submoduleChange is a submodule whose recorded commit differs between two
+// commits. An empty from or to means the submodule was added or removed.
+type submoduleChange struct {
+	path     string
+	from, to string
+}
+
+// hasSubmodules reports whether the work tree has a .gitmodules file
+func hasSubmodules() bool {
+	top, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(top, ".gitmodules"))
+	return err == nil
+}
+
+// dirtySubmodules returns the submodules with uncommitted changes, new
+// commits or untracked files, each with a description of what is dirty
+func dirtySubmodules() map[string]string {
+	output, err := gitOutput("status", "--porcelain=v2", "--ignore-submodules=none")
+	if err != nil {
+		return nil
+	}
+
+	dirty := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		// "1 XY S<c><m><u> mH mI mW hH hI path" for submodules
+		fields := strings.SplitN(line, " ", 9)
+		if len(fields) != 9 || fields[0] != "1" || !strings.HasPrefix(fields[2], "S") {
+			continue
+		}
+		var what []string
+		if fields[2][1] == 'C' {
+			what = append(what, "new commits")
+		}
+		if fields[2][2] == 'M' {
+			what = append(what, "modified content")
+		}
+		if fields[2][3] == 'U' {
+			what = append(what, "untracked files")
+		}
+		if len(what) > 0 {
+			dirty[fields[8]] = strings.Join(what, ", ")
+		}
+	}
+	return dirty
+}
+
+// warnDirtySubmodules prints the submodules with local changes
+func warnDirtySubmodules() {
+	if !hasSubmodules() {
+		return
+	}
+	dirty := dirtySubmodules()
+	if len(dirty) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(dirty))
+	for path := range dirty {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Println("Warning: these submodules have local changes that are not stashed:")
+	for _, path := range paths {
+		fmt.Printf("  %s (%s)\n", path, dirty[path])
+	}
+}
+
+// changedSubmodules returns the submodules whose recorded commit differs
+// between the commits from and to
+func changedSubmodules(from, to string) []submoduleChange {
+	output, err := gitOutput("diff", "--raw", "-z", "--no-abbrev", "--no-renames", "-r", from, to)
+	if err != nil {
+		return nil
+	}
+	return parseSubmoduleChanges(output)
+}
+
+// parseSubmoduleChanges picks the submodules out of git diff --raw -z
+// output, where every entry is ":oldmode newmode oldsha newsha status" and
+// the path, each terminated by a NUL
+func parseSubmoduleChanges(output string) []submoduleChange {
+	fields := splitNul(output)
+	var changes []submoduleChange
+	for i := 0; i+1 < len(fields); i += 2 {
+		meta, path := strings.Fields(fields[i]), fields[i+1]
+		if len(meta) != 5 || (meta[0] != ":160000" && meta[1] != "160000") {
+			continue
+		}
+		change := submoduleChange{path: path}
+		if meta[0] == ":160000" {
+			change.from = meta[2]
+		}
+		if meta[1] == "160000" {
+			change.to = meta[3]
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// syncSubmodules reports submodules whose recorded commit changed since
+// before and updates them according to gch.submodules: always, ask (the
+// default) or never
+func syncSubmodules(before string) error {
+	after, err := gitOutput("rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil || before == "" || before == after {
+		return nil
+	}
+	changes := changedSubmodules(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	fmt.Println("Submodules changed:")
+	for _, c := range changes {
+		switch {
+		case c.from == "":
+			fmt.Printf("  %s added at %s\n", c.path, c.to[:7])
+		case c.to == "":
+			fmt.Printf("  %s removed\n", c.path)
+		default:
+			fmt.Printf("  %s %s -> %s\n", c.path, c.from[:7], c.to[:7])
+		}
+	}
+
+	// git already updated them as part of the checkout
+	if recurse, _ := gitOutput("config", "--bool", "submodule.recurse"); recurse == "true" {
+		return nil
+	}
+
+	update := false
+	switch policy := config().get("gch.submodules", submodulesAsk); policy {
+	case submodulesAlways:
+		update = true
+	case submodulesNever:
+	case submodulesAsk:
+		if isInteractive() {
+			choice, err := promptChoice("Submodules changed. Update them?", []string{
+				"Update submodules (git submodule update --init --recursive)",
+				"Leave them as they are",
+			})
+			if err != nil {
+				return err
+			}
+			update = choice == 0
+		}
+	default:
+		return fmt.Errorf("invalid gch.submodules %q (expected always, ask or never)", policy)
+	}
+
+	if !update {
+		fmt.Println("Run `git submodule update --init --recursive` to update them.")
+		return nil
+	}
+	return execGitCommand("submodule", "update", "--init", "--recursive")
+}
@@ -0,0 +1,44 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSubmoduleChanges(t *testing.T) {
+	const (
+		before = "1111111111111111111111111111111111111111"
+		after  = "2222222222222222222222222222222222222222"
+		zero   = "0000000000000000000000000000000000000000"
+	)
+	entry := func(oldMode, newMode, oldHash, newHash, status, path string) string {
+		return ":" + strings.Join([]string{oldMode, newMode, oldHash, newHash, status}, " ") + "\x00" + path + "\x00"
+	}
+	tests := []struct {
+		name   string
+		output string
+		want   []submoduleChange
+	}{
+		{"updated", entry("160000", "160000", before, after, "M", "lib/dep"),
+			[]submoduleChange{{path: "lib/dep", from: before, to: after}}},
+		{"added", entry("000000", "160000", zero, after, "A", "dep"),
+			[]submoduleChange{{path: "dep", to: after}}},
+		{"removed", entry("160000", "000000", before, zero, "D", "dep"),
+			[]submoduleChange{{path: "dep", from: before}}},
+		{"files are skipped", entry("100644", "100644", before, after, "M", "README.md") + entry("160000", "160000", before, after, "M", "dep"),
+			[]submoduleChange{{path: "dep", from: before, to: after}}},
+		{"path with a tab and a newline", entry("160000", "160000", before, after, "M", "odd\tname\nhere"),
+			[]submoduleChange{{path: "odd\tname\nhere", from: before, to: after}}},
+		{"path with spaces", entry("160000", "160000", before, after, "M", " spaced dep "),
+			[]submoduleChange{{path: " spaced dep ", from: before, to: after}}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSubmoduleChanges(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSubmoduleChanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+var (
+	hasSwitchOnce sync.Once
+	hasSwitch     bool
+)
+
+// gitVersion matches the version printed by git --version
+var gitVersion = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// useSwitch reports whether to use `git switch`, added in git 2.23. Older
+// versions get the equivalent `git checkout` commands, and so does leaving an
+// unfinished rebase, merge, am, cherry-pick or revert, which `git switch`
+// refuses even when the user chose to switch anyway.
+func useSwitch() bool {
+	hasSwitchOnce.Do(func() {
+		output, err := gitOutput("--version")
+		if err != nil {
+			return
+		}
+		m := gitVersion.FindStringSubmatch(output)
+		if m == nil {
+			return
+		}
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+		hasSwitch = major > 2 || (major == 2 && minor >= 23)
+	})
+	if !hasSwitch {
+		return false
+	}
+	// git switch only warns about a bisect
+	op, pending := pendingOperationInProgress()
+	return !pending || op.name == "bisect"
+}
+
+// switchArgs returns the arguments that switch to an existing local branch
+func switchArgs(name string) []string {
+	if useSwitch() {
+		return []string{"switch", name}
+	}
+	return []string{"checkout", name}
+}
+
+// detachArgs returns the arguments that check out rev as a detached HEAD
+func detachArgs(rev string) []string {
+	if useSwitch() {
+		return []string{"switch", "--detach", rev}
+	}
+	return []string{"checkout", "--detach", rev}
+}
+
+// createArgs returns the arguments that create the branch name at start and
+// switch to it. track is "--track", "--no-track" or empty to leave the
+// upstream to branch.autoSetupMerge.
+func createArgs(name, start, track string) []string {
+	args := []string{"checkout", "-b", name}
+	if useSwitch() {
+		args = []string{"switch", "--create", name}
+	}
+	if track != "" {
+		args = append(args, track)
+	}
+	return append(args, start)
+}
+
+// remoteBranchArgs returns the arguments that create a local branch for a
+// remote-only branch. When git would guess the same remote branch from the
+// name alone, as `git switch <name>` does with checkout.guess, the name is
+// passed as is, so git applies checkout.defaultRemote and
+// branch.autoSetupMerge exactly as it would for the user. Otherwise the
+// branch is created from the remote branch, with branch.autoSetupMerge
+// deciding about the upstream.
+func remoteBranchArgs(b Branch) []string {
+	if gitConfigBool("checkout.guess", true) && guessedRemote(b.Name) == b.Remote {
+		return switchArgs(b.Name)
+	}
+	return createArgs(b.Name, b.RemoteRef(), "")
+}
+
+// guessedRemote returns the remote git picks for a branch that only exists
+// on remotes: the only remote that has it, or checkout.defaultRemote
+func guessedRemote(name string) string {
+	remotes, err := listRemotes()
+	if err != nil {
+		return ""
+	}
+	var found []string
+	for _, remote := range remotes {
+		if _, err := gitOutput("rev-parse", "--verify", "--quiet", "refs/remotes/"+remote+"/"+name); err == nil {
+			found = append(found, remote)
+		}
+	}
+	if len(found) == 1 {
+		return found[0]
+	}
+	defaultRemote := gitConfigValue("checkout.defaultRemote")
+	for _, remote := range found {
+		if remote == defaultRemote {
+			return remote
+		}
+	}
+	return ""
+}
+
+// gitConfigValue returns a setting of git itself, outside the gch section
+func gitConfigValue(key string) string {
+	value, _ := gitOutput("config", "--get", key)
+	return value
+}
+
+// gitConfigBool returns a boolean setting of git itself, or def when it
+// isn't set
+func gitConfigBool(key string, def bool) bool {
+	switch value, _ := gitOutput("config", "--bool", "--get", key); value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return def
+}
+
+// preferDefaultRemote drops remote-only matches for branches that also
+// match on checkout.defaultRemote, so a branch on several remotes resolves
+// like `git switch <name>` does instead of being ambiguous
+func preferDefaultRemote(matches []branchMatch) []branchMatch {
+	defaultRemote := gitConfigValue("checkout.defaultRemote")
+	if defaultRemote == "" {
+		return matches
+	}
+	onDefault := make(map[string]bool)
+	for _, m := range matches {
+		if !m.branch.IsLocal && m.branch.Kind == KindBranch && m.branch.Remote == defaultRemote {
+			onDefault[m.branch.Name] = true
+		}
+	}
+	kept := matches[:0]
+	for _, m := range matches {
+		if !m.branch.IsLocal && m.branch.Kind == KindBranch && m.branch.Remote != defaultRemote && onDefault[m.branch.Name] {
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// confirmGuess asks before creating a local branch for a remote-only match
+// when checkout.guess is off, since git wouldn't do that on its own
+func confirmGuess(b Branch) error {
+	if b.IsLocal || b.Kind != KindBranch || gitConfigBool("checkout.guess", true) {
+		return nil
+	}
+	if !isInteractive() {
+		return fmt.Errorf("'%s' only exists on %s and checkout.guess is off; check it out with `git switch --track %s`",
+			b.Name, b.Remote, b.RemoteRef())
+	}
+	choice, err := promptChoice(fmt.Sprintf("'%s' only exists on %s. Create a local branch for it?", b.Name, b.Remote), []string{
+		"Create a local branch from " + b.RemoteRef(),
+		"Abort",
+	})
+	if err != nil {
+		return err
+	}
+	if choice != 0 {
+		return fmt.Errorf("checkout aborted")
+	}
+	return nil
+}
+
+// switchHead runs a command that moves HEAD. It refuses while a rebase,
+// merge or similar is in progress, unless the user resolves or overrides
+// it. Dirty submodules are reported first, since stashing doesn't cover
+// them. Afterwards a WIP commit gch made on the new branch is undone,
+// submodules whose recorded commit changed are updated according to
+// gch.submodules, and the post-checkout rules for the changed files run.
+func switchHead(run func() error) error {
+	if err := guardPendingOperation(); err != nil {
+		return err
+	}
+	before, _ := gitOutput("rev-parse", "--verify", "--quiet", "HEAD")
+	warnDirtySubmodules()
+
+	if err := run(); err != nil {
+		return err
+	}
+	if err := restoreWIP(); err != nil {
+		return err
+	}
+	if err := syncSubmodules(before); err != nil {
+		return err
+	}
+	return runCheckoutRules(before)
+}
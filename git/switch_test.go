@@ -0,0 +1,134 @@
+package git
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// useGitSwitch pretends git does (2.23 and later) or doesn't have
+// `git switch` for the rest of the test
+func useGitSwitch(t *testing.T, has bool) {
+	t.Helper()
+	hasSwitchOnce.Do(func() {})
+	hasSwitch = has
+	t.Cleanup(func() {
+		hasSwitchOnce, hasSwitch = sync.Once{}, false
+	})
+}
+
+// addRemoteBranches adds each remote, pointing at the repository itself,
+// with a remote-tracking branch for each of names
+func addRemoteBranches(t *testing.T, remotes []string, names ...string) {
+	t.Helper()
+	for _, remote := range remotes {
+		runGit(t, "remote", "add", remote, ".")
+		for _, name := range names {
+			runGit(t, "update-ref", "refs/remotes/"+remote+"/"+name, "main")
+		}
+	}
+}
+
+func TestCreateArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		hasSwitch bool
+		track     string
+		want      []string
+	}{
+		{"git switch", true, "", []string{"switch", "--create", "feature", "origin/main"}},
+		{"git switch with tracking", true, "--track", []string{"switch", "--create", "feature", "--track", "origin/main"}},
+		{"before git 2.23", false, "", []string{"checkout", "-b", "feature", "origin/main"}},
+		{"before git 2.23 without tracking", false, "--no-track", []string{"checkout", "-b", "feature", "--no-track", "origin/main"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n"})
+			useGitSwitch(t, tt.hasSwitch)
+			if got := createArgs("feature", "origin/main", tt.track); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("createArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteBranchArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		hasSwitch bool
+		remotes   []string
+		// config is git config to set, as key and value pairs
+		config      []string
+		remote      string
+		wantGuessed string
+		want        []string
+	}{
+		{"only remote", true, []string{"origin"}, nil, "origin",
+			"origin", []string{"switch", "feature"}},
+		{"only remote before git 2.23", false, []string{"origin"}, nil, "origin",
+			"origin", []string{"checkout", "feature"}},
+		{"several remotes", true, []string{"origin", "upstream"}, nil, "upstream",
+			"", []string{"switch", "--create", "feature", "upstream/feature"}},
+		{"several remotes before git 2.23", false, []string{"origin", "upstream"}, nil, "upstream",
+			"", []string{"checkout", "-b", "feature", "upstream/feature"}},
+		{"default remote", true, []string{"origin", "upstream"}, []string{"checkout.defaultRemote", "upstream"}, "upstream",
+			"upstream", []string{"switch", "feature"}},
+		{"other than the default remote", true, []string{"origin", "upstream"}, []string{"checkout.defaultRemote", "upstream"}, "origin",
+			"upstream", []string{"switch", "--create", "feature", "origin/feature"}},
+		{"checkout.guess off", true, []string{"origin"}, []string{"checkout.guess", "false"}, "origin",
+			"origin", []string{"switch", "--create", "feature", "origin/feature"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n"})
+			useGitSwitch(t, tt.hasSwitch)
+			addRemoteBranches(t, tt.remotes, "feature")
+			for i := 0; i < len(tt.config); i += 2 {
+				runGit(t, "config", tt.config[i], tt.config[i+1])
+			}
+
+			if got := guessedRemote("feature"); got != tt.wantGuessed {
+				t.Errorf("guessedRemote() = %q, want %q", got, tt.wantGuessed)
+			}
+			b := Branch{Name: "feature", Kind: KindBranch, Remote: tt.remote}
+			if got := remoteBranchArgs(b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("remoteBranchArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreferDefaultRemote(t *testing.T) {
+	remoteBranch := func(remote, name string) branchMatch {
+		return branchMatch{branch: Branch{Name: name, Kind: KindBranch, Remote: remote}}
+	}
+	matches := []branchMatch{
+		{branch: Branch{Name: "feature-local", Kind: KindBranch, IsLocal: true}},
+		remoteBranch("origin", "feature"),
+		remoteBranch("upstream", "feature"),
+		remoteBranch("origin", "feature-only-origin"),
+	}
+
+	tests := []struct {
+		name          string
+		defaultRemote string
+		want          []branchMatch
+	}{
+		{"no default remote", "", matches},
+		{"default remote", "upstream", []branchMatch{matches[0], matches[2], matches[3]}},
+		{"default remote without the branch", "fork", matches},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n"})
+			if tt.defaultRemote != "" {
+				runGit(t, "config", "checkout.defaultRemote", tt.defaultRemote)
+			}
+			// preferDefaultRemote filters in place
+			input := append([]branchMatch(nil), matches...)
+			if got := preferDefaultRemote(input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("preferDefaultRemote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
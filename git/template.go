@@ -0,0 +1,197 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// defaultBranchTemplate is used when gch.branchTemplate isn't set
+	defaultBranchTemplate = "{{type}}/{{ticket}}-{{slug}}"
+	// defaultBranchType is used when neither --type nor gch.defaultType is set
+	defaultBranchType = "feat"
+	// defaultSlugMaxLength is used when gch.slugMaxLength isn't set
+	defaultSlugMaxLength = 40
+	// defaultTicketPattern matches Jira style ticket IDs like PROJ-123
+	defaultTicketPattern = `[A-Z][A-Z0-9]+-[0-9]+`
+)
+
+// templateVar matches a {{name}} placeholder in a branch template
+var templateVar = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+
+// danglingSeparators matches separators left behind by empty variables,
+// e.g. the "-" in "feat/-add-login" when there is no ticket
+var danglingSeparators = regexp.MustCompile(`([/_.-])[/_.-]+`)
+
+// BranchNameVars are the values available to a branch name template
+type BranchNameVars struct {
+	Type   string
+	Ticket string
+	Title  string
+}
+
+// renderBranchName renders gch.branchTemplate with vars. Supported
+// placeholders are {{type}}, {{ticket}}, {{slug}} (the slugified title) and
+// {{user}} (the slugified git user name). The result is validated with
+// git check-ref-format.
+func renderBranchName(vars BranchNameVars) (string, error) {
+	cfg := config()
+	if vars.Type == "" {
+		vars.Type = cfg.get("gch.defaultType", defaultBranchType)
+	}
+
+	values := map[string]string{
+		"type":   vars.Type,
+		"ticket": vars.Ticket,
+		"slug":   slugify(vars.Title, cfg.getInt("gch.slugMaxLength", defaultSlugMaxLength)),
+	}
+
+	var unknown []string
+	name := templateVar.ReplaceAllStringFunc(cfg.get("gch.branchTemplate", defaultBranchTemplate), func(match string) string {
+		key := strings.ToLower(templateVar.FindStringSubmatch(match)[1])
+		if key == "user" {
+			user, _ := gitOutput("config", "user.name")
+			return slugify(user, 0)
+		}
+		value, ok := values[key]
+		if !ok {
+			unknown = append(unknown, key)
+		}
+		return value
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown branch template variables: %s", strings.Join(unknown, ", "))
+	}
+
+	// Clean up separators around empty variables
+	name = danglingSeparators.ReplaceAllStringFunc(name, func(seps string) string {
+		if strings.Contains(seps, "/") {
+			return "/"
+		}
+		return seps[:1]
+	})
+	name = strings.Trim(name, "/-_.")
+
+	if err := checkRefFormat(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// slugify turns text into a lower case, hyphen separated slug. Accents are
+// stripped, letters of other scripts are kept, and the slug is cut at a word
+// boundary so it is at most maxLength runes long (0 means no limit).
+func slugify(text string, maxLength int) string {
+	var sb strings.Builder
+	pendingHyphen := false
+	for _, r := range norm.NFKD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop combining marks left over from decomposing accents
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if pendingHyphen && sb.Len() > 0 {
+				sb.WriteRune('-')
+			}
+			pendingHyphen = false
+			sb.WriteRune(unicode.ToLower(r))
+		default:
+			pendingHyphen = true
+		}
+	}
+
+	slug := []rune(norm.NFC.String(sb.String()))
+	if maxLength <= 0 || len(slug) <= maxLength {
+		return string(slug)
+	}
+
+	// Prefer cutting at the last hyphen within the limit
+	cut := string(slug[:maxLength])
+	if i := strings.LastIndex(cut, "-"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, "-")
+}
+
+// checkRefFormat validates a branch name with git check-ref-format
+func checkRefFormat(name string) error {
+	if name == "" {
+		return fmt.Errorf("branch name is empty")
+	}
+	if _, err := gitOutput("check-ref-format", "--branch", name); err != nil {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	return nil
+}
+
+// ticketPattern returns the regular expression that matches ticket IDs
+func ticketPattern() (*regexp.Regexp, error) {
+	pattern := config().get("gch.ticketPattern", defaultTicketPattern)
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gch.ticketPattern: %w", err)
+	}
+	return re, nil
+}
+
+// splitTicket splits a leading ticket ID off text. When text doesn't start
+// with a ticket ID, all of it is the title.
+func splitTicket(text string) (ticket, title string, err error) {
+	re, err := ticketPattern()
+	if err != nil {
+		return "", "", err
+	}
+	first, rest, _ := strings.Cut(strings.TrimSpace(text), " ")
+	if loc := re.FindStringIndex(first); loc != nil && loc[0] == 0 && loc[1] == len(first) {
+		return first, strings.TrimSpace(rest), nil
+	}
+	return "", strings.TrimSpace(text), nil
+}
+
+// newBranchNameFromQuery returns the branch a free-form query creates. A
+// query without spaces is used as the name, anything else is rendered
+// through the template with an optional leading ticket ID.
+func newBranchNameFromQuery(query string) (string, error) {
+	if !strings.ContainsAny(query, " \t") {
+		return query, checkRefFormat(query)
+	}
+	ticket, title, err := splitTicket(query)
+	if err != nil {
+		return "", err
+	}
+	return renderBranchName(BranchNameVars{Ticket: ticket, Title: title})
+}
+
+// NewBranch renders a branch name from the template and creates it. The
+// ticket is optional; when it doesn't look like a ticket ID it is treated as
+// the first word of the title. Without a title, the issue's summary from the
+// issue tracker is used.
+func NewBranch(ticket, title string, opts Options) error {
+	ticket, title, err := splitTicket(ticket + " " + title)
+	if err != nil {
+		return err
+	}
+	if title == "" && ticket == "" {
+		return fmt.Errorf("a ticket or a title is required")
+	}
+
+	// Build the slug from the issue's summary when only a ticket is given
+	if title == "" {
+		summary, err := issueTitle(ticket, opts.Offline)
+		if err != nil {
+			fmt.Printf("Warning: failed to look up %s: %v\n", ticket, err)
+		}
+		title = summary
+	}
+
+	name, err := renderBranchName(BranchNameVars{Type: opts.Type, Ticket: ticket, Title: title})
+	if err != nil {
+		return err
+	}
+
+	defer trackOperation("new")()
+	return createNewBranch(name, opts)
+}
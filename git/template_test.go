@@ -0,0 +1,101 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      string
+	}{
+		{"words", "Add login page", 0, "add-login-page"},
+		{"repeated separators", "fix --  the__bug!!", 0, "fix-the-bug"},
+		{"leading and trailing separators", "  -- hello world -- ", 0, "hello-world"},
+		{"accents", "Crème brûlée für Ärzte", 0, "creme-brulee-fur-arzte"},
+		{"other scripts", "Ошибка входа 登录", 0, "ошибка-входа-登录"},
+		{"digits", "Upgrade to v2.3", 0, "upgrade-to-v2-3"},
+		{"cut at a word boundary", "add the new login page", 15, "add-the-new"},
+		{"cut a single long word", "internationalization", 10, "internatio"},
+		{"limit counts runes", "ééé ééé", 5, "eee"},
+		{"exactly the limit", "add login", 9, "add-login"},
+		{"empty", "", 40, ""},
+		{"only separators", "?! --", 40, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slugify(tt.text, tt.maxLength); got != tt.want {
+				t.Errorf("slugify(%q, %d) = %q, want %q", tt.text, tt.maxLength, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderBranchName(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     gchConfig
+		vars    BranchNameVars
+		want    string
+		wantErr bool
+	}{
+		{"default template", gchConfig{}, BranchNameVars{Ticket: "PROJ-12", Title: "Add login"}, "feat/PROJ-12-add-login", false},
+		{"explicit type", gchConfig{}, BranchNameVars{Type: "fix", Title: "Crash on start"}, "fix/crash-on-start", false},
+		{"configured default type", gchConfig{"gch.defaulttype": {"chore"}}, BranchNameVars{Title: "Bump deps"}, "chore/bump-deps", false},
+		{"no ticket", gchConfig{}, BranchNameVars{Title: "Add login"}, "feat/add-login", false},
+		{"empty title", gchConfig{}, BranchNameVars{Ticket: "PROJ-12"}, "feat/PROJ-12", false},
+		{"slug length", gchConfig{"gch.slugmaxlength": {"10"}}, BranchNameVars{Title: "add the new login page"}, "feat/add-the", false},
+		{"user", gchConfig{"gch.branchtemplate": {"{{user}}/{{slug}}"}}, BranchNameVars{Title: "Add login"}, "jane-doe/add-login", false},
+		{"spaces in placeholders", gchConfig{"gch.branchtemplate": {"{{ type }}/{{ Slug }}"}}, BranchNameVars{Title: "x"}, "feat/x", false},
+		{"unknown variable", gchConfig{"gch.branchtemplate": {"{{type}}/{{team}}"}}, BranchNameVars{Title: "x"}, "", true},
+		{"nothing left", gchConfig{"gch.branchtemplate": {"{{ticket}}-{{slug}}"}}, BranchNameVars{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t, map[string]string{"a.txt": "a\n"})
+			runGit(t, "config", "user.name", "Jane Doe")
+			useConfig(t, tt.cfg)
+
+			got, err := renderBranchName(tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderBranchName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("renderBranchName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitTicket(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        gchConfig
+		text       string
+		wantTicket string
+		wantTitle  string
+	}{
+		{"ticket and title", gchConfig{}, "PROJ-12 Add login", "PROJ-12", "Add login"},
+		{"only a ticket", gchConfig{}, "  PROJ-12  ", "PROJ-12", ""},
+		{"no ticket", gchConfig{}, "Add login", "", "Add login"},
+		{"ticket later in the text", gchConfig{}, "Add PROJ-12 login", "", "Add PROJ-12 login"},
+		{"ticket prefix of a word", gchConfig{}, "PROJ-12x Add login", "", "PROJ-12x Add login"},
+		{"lower case", gchConfig{}, "proj-12 Add login", "", "proj-12 Add login"},
+		{"custom pattern", gchConfig{"gch.ticketpattern": {`#\d+`}}, "#42 Fix crash", "#42", "Fix crash"},
+		{"empty", gchConfig{}, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, tt.cfg)
+			ticket, title, err := splitTicket(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ticket != tt.wantTicket || title != tt.wantTitle {
+				t.Errorf("splitTicket(%q) = %q, %q, want %q, %q", tt.text, ticket, title, tt.wantTicket, tt.wantTitle)
+			}
+		})
+	}
+}
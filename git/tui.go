@@ -5,65 +5,160 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/sahilm/fuzzy"
 )
 
 // Model represents the TUI model for branch selection
 type branchModel struct {
-	branches        []Branch
-	filteredIdx     []int
-	selected        int
-	query           string
-	width           int
-	height          int
-	showRemotes     bool
-	debugMode       bool
-	showStashPrompt bool
-	stashPrompt     *stashPromptModel
+	branches    []Branch
+	filteredIdx []int
+	selected    int
+	query       string
+	input       textinput.Model
+	normalMode  bool
+	width       int
+	height      int
+	showRemotes bool
+	debugMode   bool
+	opts        Options
+	result      *selection
+	columns     []column
+	showColumns bool
+	marked      map[string]bool
+	bulk        *bulkModel
+	fetching    bool
+	fetch       *backgroundFetch
+	spinner     spinner.Model
+	warning     string
+	offline     bool
+	lookingUp   bool
+	lookupDone  bool
+
+	// Open pull requests linked to branches, refreshed in the background
+	pulls        []PullRequest
+	loadingPulls bool
+
+	// Issues of the tickets named in branches, looked up in the background
+	issues        map[string]Issue
+	loadingIssues []string
+
+	// When nothing matches, the query offers to create a branch
+	createName     string
+	createProblems []string
+	createBase     string
+	pickingBase    bool
+	savedQuery     string
+
+	// detached describes HEAD when it isn't on a branch
+	detached string
+	// pending describes a rebase, merge or similar in progress
+	pending string
 }
 
-// Branch represents a git branch
-type Branch struct {
-	Name    string
-	IsLocal bool
-	Current bool
+// selection is what the user chose in the selector. It is applied after the
+// selector exits, through the same code paths the command line uses.
+type selection struct {
+	branch Branch
+	// create is the name of a branch to create instead of checking out
+	create string
+	// base is the pattern of the base for a created branch
+	base string
 }
 
-// String returns the string representation of a branch
-func (b Branch) String() string {
-	if b.Current {
-		return "* " + b.Name
+// apply checks out or creates the selected branch
+func (s selection) apply(opts Options) error {
+	if s.create != "" {
+		if s.base != "" {
+			opts.From = s.base
+		}
+		return createNewBranch(s.create, opts)
+	}
+	if s.branch.Kind != KindBranch {
+		return checkoutRef(s.branch, opts)
 	}
+	return checkoutBranch(s.branch, opts)
+}
 
-	if !b.IsLocal {
-		return "  " + b.Name + " (remote)"
+// runSelector runs the selector and applies the selection, if any
+func runSelector(model branchModel, programOpts ...tea.ProgramOption) error {
+	result, err := tea.NewProgram(model, programOpts...).Run()
+	// Don't let the fetch race the checkout or outlive gch
+	if model.fetch != nil {
+		model.fetch.stop()
+	}
+	if err != nil {
+		return err
 	}
+	m, ok := result.(branchModel)
+	if !ok || m.result == nil {
+		return nil
+	}
+	return m.result.apply(m.opts)
+}
 
-	return "  " + b.Name
+// newSearchInput creates the text input used for the search query
+func newSearchInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "Search: "
+	input.Placeholder = "type to filter branches"
+	input.Focus()
+	return input
 }
 
 // Initial model
-func initialBranchModel(debugMode bool) (branchModel, error) {
-	// Fetch latest remote information
-	if err := execGitCommand("fetch", "--quiet"); err != nil {
-		return branchModel{}, fmt.Errorf("failed to fetch remote branches: %w", err)
+func initialBranchModel(opts Options) (branchModel, error) {
+	columns, err := parseColumns(opts.Columns)
+	if err != nil {
+		return branchModel{}, err
 	}
 
-	// Get branches
-	branches, err := getAllBranches()
+	// Open from the refs we already have. Remote information is refreshed
+	// in the background once the selector is shown.
+	branches, err := getRefs(opts)
 	if err != nil {
 		return branchModel{}, err
 	}
+	pulls, stale := loadPullRequests(opts.Offline)
+	attachPullRequests(branches, pulls)
+	issues, staleIssues := cachedIssues(issueKeys(branches))
+	attachIssues(branches, issues)
+	if opts.Offline {
+		staleIssues = nil
+	}
+
+	var fetch *backgroundFetch
+	if shouldFetch(opts.Offline) {
+		fetch = startFetch()
+	}
 
 	model := branchModel{
 		branches:    branches,
 		selected:    0,
 		query:       "",
+		input:       newSearchInput(),
 		width:       80,
 		height:      20,
 		showRemotes: true,
-		debugMode:   debugMode,
+		debugMode:   opts.Debug,
+		opts:        opts,
+		columns:     columns,
+		showColumns: len(columns) > 0,
+		fetching:    fetch != nil,
+		fetch:       fetch,
+		offline:     opts.Offline,
+		spinner:     spinner.New(spinner.WithSpinner(spinner.Dot)),
+
+		pulls:        pulls,
+		loadingPulls: stale,
+
+		issues:        issues,
+		loadingIssues: staleIssues,
+
+		detached: describeDetached(),
+		pending:  describePending(),
 	}
 
 	// Initial filter (show all branches)
@@ -82,13 +177,15 @@ func (m *branchModel) filter(query string) {
 		for i := range m.branches {
 			m.filteredIdx[i] = i
 		}
+		// Nothing is left to create once the query is cleared
+		m.updateCreate()
 		return
 	}
 
 	// Create slice of branch names for fuzzy matching
 	var names []string
 	for _, b := range m.branches {
-		names = append(names, b.Name)
+		names = append(names, b.searchText())
 	}
 
 	// Perform fuzzy matching
@@ -102,48 +199,230 @@ func (m *branchModel) filter(query string) {
 	if len(m.filteredIdx) > 0 && m.selected >= len(m.filteredIdx) {
 		m.selected = 0
 	}
+
+	m.updateCreate()
+}
+
+// updateCreate works out which branch the query would create when nothing
+// matches, and whether that name breaks the naming policy. Queries with
+// spaces are a title and rendered through the branch name template.
+func (m *branchModel) updateCreate() {
+	// Keep the name while the base is being picked
+	if m.pickingBase {
+		return
+	}
+
+	m.createName, m.createProblems = "", nil
+	query := strings.TrimSpace(m.query)
+	if len(m.filteredIdx) > 0 || query == "" {
+		return
+	}
+
+	name, err := newBranchNameFromQuery(query)
+	if err != nil {
+		m.createName = query
+		m.createProblems = []string{err.Error()}
+		return
+	}
+	m.createName = name
+
+	policy, err := loadNamingPolicy()
+	if err != nil {
+		m.createProblems = []string{err.Error()}
+		return
+	}
+	m.createProblems = policy.violations(name)
+	if suggestion := policy.suggest(name); suggestion != "" {
+		m.createProblems = append(m.createProblems, "suggestion: "+suggestion)
+	}
+}
+
+// reload re-reads all branches, keeps the ones found via ls-remote and
+// reapplies the current filter, while keeping the selection on the same
+// branch where possible
+func (m *branchModel) reload() error {
+	branches, err := getRefs(m.opts)
+	if err != nil {
+		return err
+	}
+	attachPullRequests(branches, m.pulls)
+	attachIssues(branches, m.issues)
+
+	var selectedKey string
+	if m.selected < len(m.filteredIdx) {
+		selectedKey = m.branches[m.filteredIdx[m.selected]].key()
+	}
+
+	// Branches only found via ls-remote aren't in the refs until fetched
+	var advertised []Branch
+	for _, b := range m.branches {
+		if b.Advertised {
+			advertised = append(advertised, b)
+		}
+	}
+	m.branches = branches
+	m.addAdvertised(advertised)
+	for i, idx := range m.filteredIdx {
+		if m.branches[idx].key() == selectedKey {
+			m.selected = i
+		}
+	}
+	if m.selected >= len(m.filteredIdx) {
+		m.selected = max(len(m.filteredIdx)-1, 0)
+	}
+	return nil
+}
+
+// addAdvertised adds branches found via ls-remote that aren't known locally
+// and reapplies the current filter
+func (m *branchModel) addAdvertised(advertised []Branch) {
+	known := make(map[string]bool, len(m.branches))
+	for _, b := range m.branches {
+		if b.Kind == KindBranch {
+			known[b.Name] = true
+		}
+	}
+	for _, b := range advertised {
+		if !known[b.Name] {
+			known[b.Name] = true
+			m.branches = append(m.branches, b)
+		}
+	}
+	m.filter(m.query)
+}
+
+// toggleMark marks or unmarks the selected branch for a bulk action and
+// moves on to the next one
+func (m *branchModel) toggleMark() {
+	if len(m.filteredIdx) == 0 {
+		return
+	}
+	key := m.branches[m.filteredIdx[m.selected]].key()
+	if m.marked == nil {
+		m.marked = make(map[string]bool)
+	}
+	if m.marked[key] {
+		delete(m.marked, key)
+	} else {
+		m.marked[key] = true
+	}
+	if m.selected < len(m.filteredIdx)-1 {
+		m.selected++
+	}
+}
+
+// stopPickingBase leaves base selection and restores the create query
+func (m *branchModel) stopPickingBase() {
+	m.pickingBase = false
+	m.input.SetValue(m.savedQuery)
+	m.input.CursorEnd()
+	m.input.Placeholder = "type to filter branches"
+	m.filter(m.savedQuery)
+}
+
+// baseLabel describes the base a created branch starts from
+func (m branchModel) baseLabel() string {
+	switch {
+	case m.createBase != "":
+		return m.createBase
+	case m.opts.From != "":
+		return m.opts.From
+	}
+	return config().get("gch.createFrom", "HEAD")
 }
 
 // Init initializes the model
 func (m branchModel) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{textinput.Blink}
+	if m.fetching || m.loadingPulls || len(m.loadingIssues) > 0 {
+		cmds = append(cmds, m.spinner.Tick)
+	}
+	if m.fetching {
+		cmds = append(cmds, m.fetch.wait())
+	}
+	if m.loadingPulls {
+		cmds = append(cmds, fetchPullRequests())
+	}
+	if len(m.loadingIssues) > 0 {
+		cmds = append(cmds, fetchIssues(m.loadingIssues))
+	}
+	return tea.Batch(cmds...)
 }
 
 // Update handles user input
 func (m branchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	// If showing stash prompt, handle it first
-	if m.showStashPrompt {
-		if m.stashPrompt == nil {
-			m.stashPrompt = createStashPromptModel()
+	// Background work is handled regardless of which screen is shown
+	switch msg := msg.(type) {
+	case fetchDoneMsg:
+		m.fetching = false
+		if msg.err != nil {
+			m.warning = "fetch failed, showing cached branches"
+			if msg.output != "" {
+				m.warning += ": " + firstLine(msg.output)
+			}
+			return m, nil
+		}
+		if err := m.reload(); err != nil {
+			m.warning = err.Error()
+		}
+		return m, nil
+
+	case remoteHeadsMsg:
+		m.lookingUp = false
+		m.lookupDone = true
+		if msg.err != nil {
+			m.warning = "remote lookup failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.addAdvertised(msg.branches)
+		return m, nil
+
+	case pullRequestsMsg:
+		m.loadingPulls = false
+		if msg.err != nil {
+			m.warning = "pull requests unavailable: " + msg.err.Error()
+			return m, nil
+		}
+		m.pulls = msg.pulls
+		attachPullRequests(m.branches, m.pulls)
+		m.filter(m.query)
+		return m, nil
+
+	case issuesMsg:
+		m.loadingIssues = nil
+		if msg.err != nil {
+			m.warning = "issues unavailable: " + msg.err.Error()
+			return m, nil
+		}
+		if m.issues == nil {
+			m.issues = make(map[string]Issue)
+		}
+		for key, issue := range msg.issues {
+			m.issues[key] = issue
 		}
+		attachIssues(m.branches, m.issues)
+		m.filter(m.query)
+		return m, nil
 
-		model, cmd := m.stashPrompt.Update(msg)
-		if model, ok := model.(*stashPromptModel); ok {
-			m.stashPrompt = model
-			if model.selected {
-				// User made a choice
-				if model.cursor == 0 {
-					// User chose to stash
-					return m, tea.Sequence(
-						tea.ExecProcess(exec.Command("git", "stash", "push", "-m", "Auto-stashed by gch"), func(err error) tea.Msg {
-							if err != nil {
-								return err
-							}
-							// After stashing, try the checkout again
-							selectedBranch := m.branches[m.filteredIdx[m.selected]]
-							var args []string
-							if selectedBranch.IsLocal {
-								args = []string{"checkout", selectedBranch.Name}
-							} else {
-								args = []string{"checkout", "-b", selectedBranch.Name, "origin/" + selectedBranch.Name}
-							}
-							return exec.Command("git", args...)
-						}),
-						tea.Quit,
-					)
-				} else {
-					// User chose to abort
-					return m, tea.Quit
+	case spinner.TickMsg:
+		if !m.fetching && !m.lookingUp && !m.loadingPulls && len(m.loadingIssues) == 0 {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	// If running a bulk action on marked branches, let it handle input
+	if m.bulk != nil {
+		cmd := m.bulk.Update(msg)
+		if m.bulk.finished {
+			ran := m.bulk.state == bulkDone
+			m.bulk = nil
+			if ran {
+				m.marked = nil
+				if err := m.reload(); err != nil {
+					return m, func() tea.Msg { return err }
 				}
 			}
 		}
@@ -152,65 +431,133 @@ func (m branchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// In insert mode every key that isn't bound below edits the query,
+		// so "q" or "j" can be searched for. Normal mode binds vim-style keys.
+		if m.normalMode {
+			switch msg.String() {
+			case "q", "esc":
+				return m, tea.Quit
+			case "i", "/", "tab":
+				m.normalMode = false
+				return m, m.input.Focus()
+			case "k":
+				msg = tea.KeyMsg{Type: tea.KeyUp}
+			case "j":
+				msg = tea.KeyMsg{Type: tea.KeyDown}
+			case "g":
+				m.selected = 0
+				return m, nil
+			case "G":
+				m.selected = max(len(m.filteredIdx)-1, 0)
+				return m, nil
+			case "x":
+				msg = tea.KeyMsg{Type: tea.KeyCtrlX}
+			case " ":
+				m.toggleMark()
+				return m, nil
+			}
+		}
+
 		switch msg.String() {
-		case "ctrl+c", "q":
+		case "ctrl+c":
+			return m, tea.Quit
+
+		case "esc":
+			if m.pickingBase {
+				m.stopPickingBase()
+				return m, nil
+			}
 			return m, tea.Quit
 
+		case "ctrl+b":
+			// Without a branch to create, Ctrl+B moves the cursor left as
+			// usual
+			if m.createName == "" || m.pickingBase {
+				var cmd tea.Cmd
+				m.input, cmd = m.input.Update(msg)
+				return m, cmd
+			}
+			// Choose the base of the branch to create from the list
+			m.pickingBase = true
+			m.savedQuery = m.query
+			m.input.SetValue("")
+			m.input.Placeholder = "pick the base branch"
+			m.filter("")
+
+		case "tab":
+			// Switch to normal mode for vim-style navigation
+			m.normalMode = true
+			m.input.Blur()
+
 		case "enter":
-			if len(m.filteredIdx) > 0 {
-				selectedBranch := m.branches[m.filteredIdx[m.selected]]
-				var args []string
-				if selectedBranch.IsLocal {
-					args = []string{"checkout", selectedBranch.Name}
-				} else {
-					args = []string{"checkout", "-b", selectedBranch.Name, "origin/" + selectedBranch.Name}
+			if m.pickingBase {
+				if len(m.filteredIdx) > 0 {
+					m.createBase = m.branches[m.filteredIdx[m.selected]].Ref()
 				}
+				m.stopPickingBase()
+				return m, nil
+			}
 
-				// Try the checkout to see if it would fail
-				cmd := exec.Command("git", args...)
-				output, err := cmd.CombinedOutput()
-				if err != nil {
-					errorMsg := string(output)
-					if strings.Contains(errorMsg, "error: Your local changes to the following files would be overwritten by checkout") ||
-						strings.Contains(errorMsg, "error: The following untracked working tree files would be overwritten by checkout") {
-						// Checkout would fail, show stash prompt
-						m.showStashPrompt = true
-						return m, nil
-					}
-					// If it's a different error, return it
-					return m, tea.Sequence(
-						tea.ExecProcess(exec.Command("git", args...), func(err error) tea.Msg {
-							return err
-						}),
-						tea.Quit,
-					)
-				}
+			if len(m.filteredIdx) > 0 {
+				m.result = &selection{branch: m.branches[m.filteredIdx[m.selected]]}
+				return m, tea.Quit
+			}
 
-				// If checkout succeeded, we're done
+			if m.createName != "" {
+				if len(m.createProblems) > 0 {
+					m.warning = "fix the branch name before creating it"
+					return m, nil
+				}
+				m.result = &selection{create: m.createName, base: m.createBase}
 				return m, tea.Quit
 			}
 
-		case "up", "k":
+		case "ctrl+t":
+			// Toggle the metadata columns
+			m.showColumns = !m.showColumns
+
+		case "ctrl+x":
+			// Open the action menu for the marked branches
+			var marked []Branch
+			for _, b := range m.branches {
+				if m.marked[b.key()] {
+					marked = append(marked, b)
+				}
+			}
+			if len(marked) == 0 && len(m.filteredIdx) > 0 {
+				marked = []Branch{m.branches[m.filteredIdx[m.selected]]}
+			}
+			if len(marked) > 0 {
+				m.bulk = newBulkModel(marked)
+			}
+
+		case "up", "ctrl+p":
 			if m.selected > 0 {
 				m.selected--
 			}
 
-		case "down", "j":
+		case "down", "ctrl+n":
 			if m.selected < len(m.filteredIdx)-1 {
 				m.selected++
 			}
 
-		case "backspace":
-			if len(m.query) > 0 {
-				m.query = m.query[:len(m.query)-1]
-				m.filter(m.query)
+		default:
+			if m.normalMode {
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.input, cmd = m.input.Update(msg)
+			if m.input.Value() != m.query {
+				m.filter(m.input.Value())
 			}
 
-		default:
-			if len(msg.String()) == 1 {
-				m.query += msg.String()
-				m.filter(m.query)
+			// Nothing matches locally: ask the remotes once, without
+			// fetching, whether they have a matching branch
+			if len(m.filteredIdx) == 0 && m.query != "" && !m.offline && !m.lookupDone && !m.lookingUp {
+				m.lookingUp = true
+				return m, tea.Batch(cmd, lookupRemoteHeads(), m.spinner.Tick)
 			}
+			return m, cmd
 		}
 	}
 
@@ -219,44 +566,93 @@ func (m branchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // View renders the UI
 func (m branchModel) View() string {
-	if m.showStashPrompt {
-		if m.stashPrompt == nil {
-			m.stashPrompt = createStashPromptModel()
-		}
-		return m.stashPrompt.View()
+	if m.bulk != nil {
+		return m.bulk.View()
 	}
 
 	var sb strings.Builder
 
+	if m.pending != "" {
+		sb.WriteString("⚠ " + m.pending + "\n")
+	}
+	if m.detached != "" {
+		sb.WriteString(m.detached + "\n")
+	}
+
 	// Show search query
-	sb.WriteString(fmt.Sprintf("Search: %s\n\n", m.query))
+	if m.pickingBase {
+		sb.WriteString(fmt.Sprintf("Choose the base for '%s'\n", m.createName))
+	}
+	sb.WriteString(m.input.View() + "\n\n")
+
+	// Render rows with aligned metadata columns
+	filtered := make([]Branch, len(m.filteredIdx))
+	for i, idx := range m.filteredIdx {
+		filtered[i] = m.branches[idx]
+	}
+	var columns []column
+	if m.showColumns {
+		columns = m.columns
+	}
+	rows := formatBranchRows(filtered, columns)
 
 	// Show branches
 	visibleCount := 0
-	for i, idx := range m.filteredIdx {
+	for i, row := range rows {
 		if visibleCount >= m.height-5 {
 			sb.WriteString("  (more branches not shown)\n")
 			break
 		}
 
-		branch := m.branches[idx]
-
+		prefix := "  "
 		if i == m.selected {
 			// Highlight selected branch
-			sb.WriteString("> " + highlightMatches(branch.String(), m.query) + "\n")
-		} else {
-			sb.WriteString("  " + highlightMatches(branch.String(), m.query) + "\n")
+			prefix = "> "
+		}
+		if m.marked[filtered[i].key()] {
+			prefix = prefix[:1] + "+"
 		}
+		sb.WriteString(prefix + highlightMatches(row, m.query) + "\n")
 
 		visibleCount++
 	}
 
-	if len(m.filteredIdx) == 0 {
+	if m.createName != "" && !m.pickingBase {
+		// Offer to create a branch named after the query
+		sb.WriteString(fmt.Sprintf("> + Create branch '%s' from %s\n", m.createName, m.baseLabel()))
+		for _, problem := range m.createProblems {
+			sb.WriteString("      " + problem + "\n")
+		}
+	} else if len(m.filteredIdx) == 0 {
 		sb.WriteString("\nNo matching branches found\n")
 	}
 
+	// Status line
+	if m.fetching {
+		sb.WriteString("\n" + m.spinner.View() + "Fetching remotes...")
+	} else if m.lookingUp {
+		sb.WriteString("\n" + m.spinner.View() + "Searching remote branches...")
+	} else if m.loadingPulls {
+		sb.WriteString("\n" + m.spinner.View() + "Loading pull requests...")
+	} else if len(m.loadingIssues) > 0 {
+		sb.WriteString("\n" + m.spinner.View() + "Loading issues...")
+	} else if m.warning != "" {
+		sb.WriteString("\n⚠ " + m.warning)
+	}
+
 	// Help text
-	sb.WriteString("\nArrow keys/j/k to navigate, Enter to select, q to quit\n")
+	if len(m.marked) > 0 {
+		sb.WriteString(fmt.Sprintf("\n%d marked, Ctrl+X for actions", len(m.marked)))
+	}
+	if m.pickingBase {
+		sb.WriteString("\nEnter to use the selected branch as base, Esc to go back\n")
+	} else if m.createName != "" {
+		sb.WriteString("\nEnter to create, Ctrl+B to choose the base, Esc to quit\n")
+	} else if m.normalMode {
+		sb.WriteString("\n-- NORMAL -- j/k to navigate, Enter to select, Space to mark, x for actions, i or / to search, Ctrl+T to toggle columns, q to quit\n")
+	} else {
+		sb.WriteString("\nArrow keys to navigate, Enter to select, Tab for normal mode and marking, Ctrl+T to toggle columns, Esc to quit\n")
+	}
 
 	return sb.String()
 }
@@ -269,70 +665,34 @@ func highlightMatches(s, query string) string {
 
 	var result string
 
-	// Simple case-insensitive highlighting
-	lowerStr := strings.ToLower(s)
-	lowerQuery := strings.ToLower(query)
+	// Simple case-insensitive highlighting. Work on runes so multi-byte
+	// characters are never split.
+	runes := []rune(s)
+	lowerStr := []rune(strings.ToLower(s))
+	lowerQuery := []rune(strings.ToLower(query))
+	if len(lowerStr) != len(runes) {
+		return s
+	}
 
 	lastIdx := 0
-	for i := range len(lowerStr) {
-		if strings.HasPrefix(lowerStr[i:], lowerQuery) {
+	for i := 0; i+len(lowerQuery) <= len(lowerStr); i++ {
+		if i >= lastIdx && string(lowerStr[i:i+len(lowerQuery)]) == string(lowerQuery) {
 			// Add text before match
-			result += s[lastIdx:i]
+			result += string(runes[lastIdx:i])
 			// Add highlighted match
-			result += s[i : i+len(query)]
-			lastIdx = i + len(query)
+			result += string(runes[i : i+len(lowerQuery)])
+			lastIdx = i + len(lowerQuery)
 		}
 	}
 
 	// Add remaining text
-	result += s[lastIdx:]
+	result += string(runes[lastIdx:])
 
 	return result
 }
 
-// execGitForTUI executes a git checkout command in a way that works with bubbletea
-func execGitForTUI(branch Branch) tea.Cmd {
-	var args []string
-	if branch.IsLocal {
-		args = []string{"checkout", branch.Name}
-	} else {
-		args = []string{"checkout", "-b", branch.Name, "origin/" + branch.Name}
-	}
-
-	// First try the checkout to see if it would fail
-	cmd := exec.Command("git", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		errorMsg := string(output)
-		if strings.Contains(errorMsg, "error: Your local changes to the following files would be overwritten by checkout") ||
-			strings.Contains(errorMsg, "error: The following untracked working tree files would be overwritten by checkout") {
-			// Checkout would fail, ask about stashing
-			return tea.Sequence(
-				tea.ExecProcess(exec.Command("git", "stash", "push", "-m", "Auto-stashed by gch"), func(err error) tea.Msg {
-					if err != nil {
-						return err
-					}
-					// After stashing, try the checkout again
-					return exec.Command("git", args...)
-				}),
-				tea.Quit,
-			)
-		}
-		// If it's a different error, return it
-		return tea.Sequence(
-			tea.ExecProcess(exec.Command("git", args...), func(err error) tea.Msg {
-				return err
-			}),
-			tea.Quit,
-		)
-	}
-
-	// If checkout succeeded, we're done
-	return tea.Quit
-}
-
 // ShowInteractiveBranchSelector shows an interactive branch selector
-func ShowInteractiveBranchSelector(debugMode bool) error {
+func ShowInteractiveBranchSelector(opts Options) error {
 	// Check if we're in an empty repository
 	cmd := exec.Command("git", "rev-parse", "HEAD")
 	if err := cmd.Run(); err != nil {
@@ -342,97 +702,72 @@ func ShowInteractiveBranchSelector(debugMode bool) error {
 		return err
 	}
 
-	model, err := initialBranchModel(debugMode)
+	model, err := initialBranchModel(opts)
 	if err != nil {
 		return err
 	}
 
-	p := tea.NewProgram(model, tea.WithAltScreen())
-	_, err = p.Run()
-	return err
+	defer trackOperation("checkout")()
+
+	return runSelector(model, tea.WithAltScreen())
 }
 
-// getAllBranches returns all branches, both local and remote
-func getAllBranches() ([]Branch, error) {
-	// Get current branch
-	currentBranch, err := getCurrentBranch()
-	if err != nil {
-		return nil, err
-	}
+// stashPromptModel represents the model for the prompt shown when local
+// changes are in the way of a checkout
+type stashPromptModel struct {
+	conflict checkoutConflict
+	// others are the untracked files a stash with -u would sweep up besides
+	// the conflicting ones
+	others   []string
+	choices  []dirtyChoice
+	cursor   int
+	selected bool
 
-	// Get local branches
-	localBranches, err := getBranches(false)
-	if err != nil {
-		return nil, err
-	}
+	// diff holds the lines of the local changes while they are shown
+	diff    []string
+	viewing bool
+	offset  int
+	height  int
+}
 
-	// Get remote branches
-	remoteBranches, err := getBranches(true)
-	if err != nil {
-		return nil, err
+// createStashPromptModel creates a new stash prompt model with the given
+// choices, the last of which aborts
+func createStashPromptModel(conflict checkoutConflict, choices []dirtyChoice) *stashPromptModel {
+	m := &stashPromptModel{conflict: conflict, choices: choices, height: 20}
+	conflicting := make(map[string]bool)
+	for _, file := range conflict.files {
+		conflicting[file] = true
 	}
-
-	// Create a map to avoid duplicates
-	branchMap := make(map[string]Branch)
-
-	// Add local branches
-	for _, name := range localBranches {
-		branchMap[name] = Branch{
-			Name:    name,
-			IsLocal: true,
-			Current: name == currentBranch,
+	for _, file := range untrackedFiles("") {
+		if !conflicting[file] {
+			m.others = append(m.others, file)
 		}
 	}
-
-	// Add remote branches that don't have a local counterpart
-	for _, name := range remoteBranches {
-		if _, exists := branchMap[name]; !exists {
-			branchMap[name] = Branch{
-				Name:    name,
-				IsLocal: false,
-				Current: false,
-			}
+	for i, choice := range m.choices {
+		if choice.action == dirtyStashAll && len(m.others) > 0 {
+			m.choices[i].label = fmt.Sprintf("Stash changes including all untracked files (git stash -u, %d more)", len(m.others))
 		}
 	}
-
-	// Convert map to slice
-	var result []Branch
-	for _, branch := range branchMap {
-		result = append(result, branch)
-	}
-
-	return result, nil
+	return m
 }
 
-// getCurrentBranch returns the current branch name
-func getCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	output, err := cmd.Output()
-	if err != nil {
-		// In an empty repository, return empty string instead of error
-		return "", err
+// localDiff returns the lines of the diff of the local changes against
+// HEAD, followed by the untracked files
+func localDiff() []string {
+	var lines []string
+	if output, err := gitOutput("diff", "--no-color", "--stat", "--patch", "HEAD"); err == nil && output != "" {
+		lines = strings.Split(output, "\n")
 	}
-
-	return strings.TrimSpace(string(output)), nil
-}
-
-// stashPromptModel represents the model for the stash prompt
-type stashPromptModel struct {
-	choices  []string
-	cursor   int
-	selected bool
-}
-
-// createStashPromptModel creates a new stash prompt model
-func createStashPromptModel() *stashPromptModel {
-	return &stashPromptModel{
-		choices: []string{
-			"Stash changes and continue",
-			"Abort checkout",
-		},
-		cursor:   0,
-		selected: false,
+	if untracked := untrackedFiles(""); len(untracked) > 0 {
+		lines = append(lines, "", "Untracked files:")
+		for _, file := range untracked {
+			lines = append(lines, "  "+file)
+		}
 	}
+	if len(lines) == 0 {
+		lines = []string{"No local changes"}
+	}
+	return lines
 }
 
 // Init initializes the model
@@ -443,7 +778,12 @@ func (m *stashPromptModel) Init() tea.Cmd {
 // Update handles messages and updates the model
 func (m *stashPromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = max(msg.Height-2, 5)
 	case tea.KeyMsg:
+		if m.viewing {
+			return m.updateDiff(msg)
+		}
 		switch msg.String() {
 		case "up", "k":
 			if m.cursor > 0 {
@@ -453,11 +793,16 @@ func (m *stashPromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.cursor < len(m.choices)-1 {
 				m.cursor++
 			}
+		case "d":
+			if m.diff == nil {
+				m.diff = localDiff()
+			}
+			m.viewing = true
 		case "enter":
 			m.selected = true
 			return m, tea.Quit
 		case "q", "esc":
-			m.cursor = 1 // Select "Abort checkout"
+			m.cursor = len(m.choices) - 1 // Select "Abort checkout"
 			m.selected = true
 			return m, tea.Quit
 		}
@@ -465,13 +810,57 @@ func (m *stashPromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateDiff scrolls the diff until it is closed
+func (m *stashPromptModel) updateDiff(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	last := max(len(m.diff)-m.height, 0)
+	switch msg.String() {
+	case "up", "k":
+		m.offset = max(m.offset-1, 0)
+	case "down", "j":
+		m.offset = min(m.offset+1, last)
+	case "pgup", "b":
+		m.offset = max(m.offset-m.height, 0)
+	case "pgdown", " ":
+		m.offset = min(m.offset+m.height, last)
+	case "g":
+		m.offset = 0
+	case "G":
+		m.offset = last
+	case "q", "esc", "d", "enter":
+		m.viewing = false
+	}
+	return m, nil
+}
+
 // View renders the model
 func (m *stashPromptModel) View() string {
 	if m.selected {
 		return ""
 	}
+	if m.viewing {
+		end := min(m.offset+m.height, len(m.diff))
+		s := strings.Join(m.diff[m.offset:end], "\n")
+		return s + fmt.Sprintf("\n\n(%d-%d of %d) j/k or Space/b to scroll, q to go back", m.offset+1, end, len(m.diff))
+	}
 
 	s := "You have uncommitted changes that would be overwritten.\n"
+	if m.conflict.untracked {
+		s = "These untracked files would be overwritten:\n"
+		for _, file := range m.conflict.files {
+			s += "  " + file + "\n"
+		}
+		if len(m.others) > 0 {
+			shown := m.others
+			if len(shown) > 5 {
+				shown = shown[:5]
+			}
+			s += "Stashing all untracked files would also take " + strings.Join(shown, ", ")
+			if more := len(m.others) - len(shown); more > 0 {
+				s += fmt.Sprintf(" and %d more", more)
+			}
+			s += ".\n"
+		}
+	}
 	s += "What would you like to do?\n\n"
 
 	for i, choice := range m.choices {
@@ -479,9 +868,9 @@ func (m *stashPromptModel) View() string {
 		if m.cursor == i {
 			cursor = ">"
 		}
-		s += fmt.Sprintf("%s %s\n", cursor, choice)
+		s += fmt.Sprintf("%s %s\n", cursor, choice.label)
 	}
 
-	s += "\nPress Enter to confirm, q or Esc to abort"
+	s += "\nPress Enter to confirm, d to view the diff, q or Esc to abort"
 	return s
 }